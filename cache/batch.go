@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"errors"
+	"reflect"
+)
+
+var ErrInvalidBatchValues = errors.New("cache: valsPtr must be a pointer to slice")
+
+// batchValues is the slice behind valsPtr of MGetCtx, it has the same length as keys.
+type batchValues struct {
+	slice reflect.Value
+}
+
+func newBatchValues(valsPtr any, n int) (batchValues, error) {
+	v := reflect.ValueOf(valsPtr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return batchValues{}, ErrInvalidBatchValues
+	}
+
+	v.Elem().Set(reflect.MakeSlice(v.Elem().Type(), n, n))
+	return batchValues{slice: v.Elem()}, nil
+}
+
+// batchValuesOf wraps valsPtr which is already filled by MGetCtx.
+func batchValuesOf(valsPtr any) batchValues {
+	return batchValues{slice: reflect.ValueOf(valsPtr).Elem()}
+}
+
+// index returns the pointer of the ith value, used as the target of unmarshal.
+func (b batchValues) index(i int) any {
+	return b.slice.Index(i).Addr().Interface()
+}
+
+// newLike makes another valsPtr with the same element type.
+func (b batchValues) newLike() any {
+	return reflect.New(b.slice.Type()).Interface()
+}
+
+func (b batchValues) set(i int, from batchValues, j int) {
+	b.slice.Index(i).Set(from.slice.Index(j))
+}
@@ -0,0 +1,690 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"runtime"
+	"time"
+
+	"github.com/eddieowens/opts"
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/mathx"
+	"github.com/zeromicro/go-zero/core/syncx"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltItemsBucket = []byte("jzero:cache:items")
+	// boltTagsBucket indexes keys by tags, the index key is tag + "\x00" + key
+	boltTagsBucket = []byte("jzero:cache:tags")
+
+	errInvalidBoltItem = errors.New("cache: invalid bolt item")
+)
+
+type (
+	// boltItem is stored as 8 bytes of the expire unix nano (0 means no expire),
+	// the uvarint count of tags, the uvarint length prefixed tags and then the encoded value.
+	boltItem struct {
+		expireAt int64
+		tags     []string
+		data     []byte
+	}
+
+	boltCache struct {
+		db             *bolt.DB
+		errNotFound    error
+		janitor        *janitor
+		barrier        syncx.SingleFlight
+		refreshing     syncx.SingleFlight
+		expiry         time.Duration
+		notFoundExpiry time.Duration
+		unstableExpiry mathx.Unstable
+		codec          Codec
+		metrics        Metrics
+		namespace      func(key string) string
+	}
+)
+
+// NewBolt creates a Cache persisted in the bolt db, the db is owned by the caller and must be closed by the caller.
+// Every write is a bolt transaction, so entries survive restarts and a crash never leaves a half written entry.
+// The returned cache is an io.Closer which stops sweeping expired entries every CleanInterval.
+func NewBolt(db *bolt.DB, errNotFound error, op ...opts.Opt[DriverOpts]) (Cache, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltItemsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltTagsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	o := newDriverOpts(op...)
+	c := &boltCache{
+		db:             db,
+		errNotFound:    errNotFound,
+		barrier:        syncx.NewSingleFlight(),
+		refreshing:     syncx.NewSingleFlight(),
+		expiry:         o.Expiry,
+		notFoundExpiry: o.NotFoundExpiry,
+		unstableExpiry: mathx.NewUnstable(expiryDeviation),
+		codec:          o.Codec,
+		metrics:        o.Metrics,
+		namespace:      o.Namespace,
+	}
+
+	if o.CleanInterval > 0 {
+		c.janitor = newJanitor(o.CleanInterval)
+		// the janitor only references the db, so the finalizer is able to stop it if Close is never called
+		go c.janitor.run(func() {
+			if err := sweepBolt(db, time.Now().UnixNano()); err != nil && !errors.Is(err, bolt.ErrDatabaseNotOpen) {
+				logx.Errorf("sweep expired bolt cache, error: %v", err)
+			}
+		})
+		runtime.SetFinalizer(c, func(c *boltCache) {
+			c.janitor.close()
+		})
+	}
+
+	return c, nil
+}
+
+// Close stops sweeping expired entries in background, the db is not closed since it is owned by the caller.
+// Close the cache before the db, otherwise the janitor may sweep a closed db.
+func (c *boltCache) Close() error {
+	if c.janitor != nil {
+		c.janitor.close()
+	}
+	return nil
+}
+
+func (c *boltCache) Del(keys ...string) error {
+	return c.DelCtx(context.Background(), keys...)
+}
+
+func (c *boltCache) DelCtx(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for _, key := range keys {
+			if _, err := removeBoltItem(tx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *boltCache) Get(key string, val any) error {
+	return c.GetCtx(context.Background(), key, val)
+}
+
+func (c *boltCache) GetCtx(ctx context.Context, key string, val any) error {
+	err := c.doGetCache(ctx, key, val)
+	if errors.Is(err, errPlaceholder) {
+		return c.errNotFound
+	}
+	return err
+}
+
+func (c *boltCache) IsNotFound(err error) bool {
+	return errors.Is(err, c.errNotFound)
+}
+
+func (c *boltCache) Set(key string, val any) error {
+	return c.SetCtx(context.Background(), key, val)
+}
+
+func (c *boltCache) SetCtx(ctx context.Context, key string, val any) error {
+	return c.SetWithExpireCtx(ctx, key, val, c.aroundDuration(expireFrom(ctx, c.expiry)))
+}
+
+func (c *boltCache) SetWithExpire(key string, val any, expire time.Duration) error {
+	return c.SetWithExpireCtx(context.Background(), key, val, expire)
+}
+
+func (c *boltCache) SetWithExpireCtx(ctx context.Context, key string, val any, expire time.Duration) error {
+	return c.set(key, val, expire)
+}
+
+func (c *boltCache) SetNoExpireCtx(ctx context.Context, key string, val any) error {
+	return c.set(key, val, 0)
+}
+
+func (c *boltCache) IncrByCtx(ctx context.Context, key string, delta int64) (int64, error) {
+	var val int64
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		item, ok, err := getBoltItem(tx, key, time.Now().UnixNano())
+		if err != nil {
+			return err
+		}
+
+		var data []byte
+		if ok {
+			data = item.data
+		}
+		if item.data, val, err = incrBy(c.codec, data, delta); err != nil {
+			return err
+		}
+		return putBoltItem(tx, key, item)
+	})
+	return val, err
+}
+
+func (c *boltCache) DecrByCtx(ctx context.Context, key string, delta int64) (int64, error) {
+	delta, err := negate(delta)
+	if err != nil {
+		return 0, err
+	}
+	return c.IncrByCtx(ctx, key, delta)
+}
+
+func (c *boltCache) SetNXCtx(ctx context.Context, key string, val any, expire time.Duration) (bool, error) {
+	data, err := c.codec.Marshal(val)
+	if err != nil {
+		return false, err
+	}
+
+	var set bool
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		if _, ok, err := getBoltItem(tx, key, time.Now().UnixNano()); err != nil || ok {
+			return err
+		}
+		set = true
+		return putBoltItem(tx, key, boltItem{expireAt: expireAt(expire), data: data})
+	})
+	return set, err
+}
+
+func (c *boltCache) CompareAndSwapCtx(ctx context.Context, key string, oldVal, newVal any) (bool, error) {
+	oldData, err := c.codec.Marshal(oldVal)
+	if err != nil {
+		return false, err
+	}
+	newData, err := c.codec.Marshal(newVal)
+	if err != nil {
+		return false, err
+	}
+
+	var swapped bool
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		item, ok, err := getBoltItem(tx, key, time.Now().UnixNano())
+		if err != nil || !ok || !bytes.Equal(item.data, oldData) {
+			return err
+		}
+		swapped = true
+		item.data = newData
+		return putBoltItem(tx, key, item)
+	})
+	return swapped, err
+}
+
+func (c *boltCache) Take(val any, key string, query func(val any) error) error {
+	return c.TakeCtx(context.Background(), val, key, query)
+}
+
+func (c *boltCache) TakeCtx(ctx context.Context, val any, key string, query func(val any) error) error {
+	return c.doTake(ctx, val, key, query, func(v any) error {
+		return c.SetCtx(ctx, key, v)
+	})
+}
+
+func (c *boltCache) TakeWithExpire(val any, key string, query func(val any, expire time.Duration) error) error {
+	return c.TakeWithExpireCtx(context.Background(), val, key, query)
+}
+
+func (c *boltCache) TakeWithExpireCtx(ctx context.Context, val any, key string, query func(val any, expire time.Duration) error) error {
+	expire := c.aroundDuration(expireFrom(ctx, c.expiry))
+	return c.doTake(ctx, val, key, func(v any) error {
+		return query(v, expire)
+	}, func(v any) error {
+		return c.SetWithExpireCtx(ctx, key, v, expire)
+	})
+}
+
+func (c *boltCache) TakeWithRefreshCtx(ctx context.Context, val any, key string, softTTL, hardTTL time.Duration, query func(val any) error) error {
+	return takeWithRefresh(ctx, c, c.refreshing, val, key, softTTL, hardTTL, query)
+}
+
+// ExpireCtx resets the expire of key, zero expire deletes the key as redis does.
+func (c *boltCache) ExpireCtx(ctx context.Context, key string, expire time.Duration) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		item, ok, err := getBoltItem(tx, key, time.Now().UnixNano())
+		if err != nil || !ok {
+			return err
+		}
+		if expire <= 0 {
+			_, err = removeBoltItem(tx, key)
+			return err
+		}
+
+		item.expireAt = expireAt(expire)
+		return putBoltItem(tx, key, item)
+	})
+}
+
+func (c *boltCache) GetPrefixKeysCtx(ctx context.Context, prefix string) ([]string, error) {
+	var allKeys []string
+	err := c.ScanPrefixCtx(ctx, prefix, defaultScanBatchSize, func(keys []string) bool {
+		allKeys = append(allKeys, keys...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allKeys, nil
+}
+
+// ScanPrefixCtx reads every batch in its own transaction, so a slow fn never holds the db.
+func (c *boltCache) ScanPrefixCtx(ctx context.Context, prefix string, batchSize int, fn func(keys []string) bool) error {
+	if batchSize <= 0 {
+		batchSize = defaultScanBatchSize
+	}
+
+	p := []byte(prefix)
+	seek := p
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var keys []string
+		err := c.db.View(func(tx *bolt.Tx) error {
+			now := time.Now().UnixNano()
+			cursor := tx.Bucket(boltItemsBucket).Cursor()
+			for k, v := cursor.Seek(seek); k != nil && bytes.HasPrefix(k, p) && len(keys) < batchSize; k, v = cursor.Next() {
+				if !boltExpired(v, now) {
+					keys = append(keys, string(k))
+				}
+				// the smallest key after k
+				seek = append(append(make([]byte, 0, len(k)+1), k...), 0)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(keys) > 0 && !fn(keys) {
+			return nil
+		}
+		if len(keys) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (c *boltCache) DelPrefixCtx(ctx context.Context, prefix string, batchSize int) (int, error) {
+	return delPrefix(ctx, c.ScanPrefixCtx, c.DelCtx, prefix, batchSize)
+}
+
+func (c *boltCache) MGetCtx(ctx context.Context, keys []string, valsPtr any) ([]string, error) {
+	vals, err := newBatchValues(valsPtr, len(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	datas := make([][]byte, len(keys))
+	err = c.db.View(func(tx *bolt.Tx) error {
+		now := time.Now().UnixNano()
+		for i, key := range keys {
+			item, ok, err := getBoltItem(tx, key, now)
+			if err != nil {
+				return err
+			}
+			if ok {
+				datas[i] = item.data
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var notFound []string
+	for i, data := range datas {
+		if data == nil {
+			c.metrics.Miss(c.namespace(keys[i]))
+			notFound = append(notFound, keys[i])
+			continue
+		}
+		c.metrics.Hit(c.namespace(keys[i]))
+		if string(data) == notFoundPlaceholder {
+			notFound = append(notFound, keys[i])
+			continue
+		}
+		if err = c.codec.Unmarshal(data, vals.index(i)); err != nil {
+			// same as redis node, an invalid value is treated as not found
+			logx.WithContext(ctx).Errorf("unmarshal cache, key: %s, value: %s, error: %v", keys[i], data, err)
+			notFound = append(notFound, keys[i])
+		}
+	}
+	return notFound, nil
+}
+
+func (c *boltCache) MSetWithExpireCtx(ctx context.Context, kvs map[string]any, expire time.Duration) error {
+	items := make(map[string]boltItem, len(kvs))
+	for key, val := range kvs {
+		data, err := c.codec.Marshal(val)
+		if err != nil {
+			return err
+		}
+		items[key] = boltItem{expireAt: expireAt(expire), data: data}
+	}
+
+	// one transaction, the batch is written entirely or not at all
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for key, item := range items {
+			if err := putBoltItem(tx, key, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *boltCache) SetWithTagsCtx(ctx context.Context, key string, val any, expire time.Duration, tags ...string) error {
+	data, err := c.codec.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return putBoltItem(tx, key, boltItem{expireAt: expireAt(expire), tags: tags, data: data})
+	})
+}
+
+func (c *boltCache) GetTagKeysCtx(ctx context.Context, tags ...string) ([]string, error) {
+	var keys []string
+	err := c.db.View(func(tx *bolt.Tx) error {
+		now := time.Now().UnixNano()
+		items := tx.Bucket(boltItemsBucket)
+		for _, tag := range tags {
+			for _, key := range boltTagKeys(tx, tag) {
+				if v := items.Get([]byte(key)); v != nil && !boltExpired(v, now) {
+					keys = append(keys, key)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return uniqueKeys(keys), nil
+}
+
+func (c *boltCache) InvalidateTagsCtx(ctx context.Context, tags ...string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for _, tag := range tags {
+			// collect first, deleting under a cursor skips entries
+			for _, key := range boltTagKeys(tx, tag) {
+				if _, err := removeBoltItem(tx, key); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (c *boltCache) aroundDuration(duration time.Duration) time.Duration {
+	return c.unstableExpiry.AroundDuration(duration)
+}
+
+func (c *boltCache) set(key string, val any, expire time.Duration) error {
+	data, err := c.codec.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return putBoltItem(tx, key, boltItem{expireAt: expireAt(expire), data: data})
+	})
+}
+
+func (c *boltCache) ttlCtx(ctx context.Context, key string) (time.Duration, bool) {
+	var (
+		item boltItem
+		ok   bool
+	)
+	if err := c.db.View(func(tx *bolt.Tx) error {
+		var err error
+		item, ok, err = getBoltItem(tx, key, time.Now().UnixNano())
+		return err
+	}); err != nil || !ok {
+		return 0, false
+	}
+	return remaining(item.expireAt)
+}
+
+func (c *boltCache) doGetCache(ctx context.Context, key string, v any) error {
+	var (
+		data []byte
+		ok   bool
+	)
+	err := c.db.View(func(tx *bolt.Tx) error {
+		var item boltItem
+		var err error
+		item, ok, err = getBoltItem(tx, key, time.Now().UnixNano())
+		data = item.data
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		c.metrics.Miss(c.namespace(key))
+		return c.errNotFound
+	}
+
+	c.metrics.Hit(c.namespace(key))
+	if string(data) == notFoundPlaceholder {
+		return errPlaceholder
+	}
+
+	if err = c.codec.Unmarshal(data, v); err != nil {
+		logger := logx.WithContext(ctx)
+		logger.Errorf("unmarshal cache, key: %s, value: %s, error: %v", key, data, err)
+		if e := c.DelCtx(ctx, key); e != nil {
+			logger.Errorf("delete invalid cache, key: %s, value: %s, error: %v", key, data, e)
+		}
+		// returns errNotFound to reload the value by the given query
+		return c.errNotFound
+	}
+	return nil
+}
+
+// doTake collapses concurrent loads of the same key and caches errNotFound as a placeholder,
+// it follows go-zero cache node.
+func (c *boltCache) doTake(ctx context.Context, v any, key string, query func(v any) error, cacheVal func(v any) error) error {
+	logger := logx.WithContext(ctx)
+	val, fresh, err := c.barrier.DoEx(key, func() (any, error) {
+		if err := c.doGetCache(ctx, key, v); err != nil {
+			if errors.Is(err, errPlaceholder) {
+				return nil, c.errNotFound
+			} else if !errors.Is(err, c.errNotFound) {
+				return nil, err
+			}
+
+			start := time.Now()
+			err = query(v)
+			c.metrics.Load(c.namespace(key), time.Since(start), loadErr(err, c.errNotFound))
+			if errors.Is(err, c.errNotFound) {
+				if err = c.setCacheWithNotFound(key); err != nil {
+					logger.Error(err)
+				}
+				return nil, c.errNotFound
+			} else if err != nil {
+				return nil, err
+			}
+
+			if err = cacheVal(v); err != nil {
+				logger.Error(err)
+			}
+		}
+
+		return c.codec.Marshal(v)
+	})
+	if err != nil {
+		return err
+	}
+	if fresh {
+		return nil
+	}
+
+	// got the result from previous ongoing query
+	c.metrics.Hit(c.namespace(key))
+	return c.codec.Unmarshal(val.([]byte), v)
+}
+
+func (c *boltCache) setCacheWithNotFound(key string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		if _, ok, err := getBoltItem(tx, key, time.Now().UnixNano()); err != nil || ok {
+			return err
+		}
+		return putBoltItem(tx, key, boltItem{
+			expireAt: expireAt(c.aroundDuration(c.notFoundExpiry)),
+			data:     []byte(notFoundPlaceholder),
+		})
+	})
+}
+
+func (item boltItem) marshal() []byte {
+	buf := make([]byte, 8, 8+binary.MaxVarintLen64+len(item.data))
+	binary.BigEndian.PutUint64(buf, uint64(item.expireAt))
+	buf = binary.AppendUvarint(buf, uint64(len(item.tags)))
+	for _, tag := range item.tags {
+		buf = binary.AppendUvarint(buf, uint64(len(tag)))
+		buf = append(buf, tag...)
+	}
+	return append(buf, item.data...)
+}
+
+// unmarshalBoltItem copies everything out of v, which is only valid in its transaction.
+func unmarshalBoltItem(v []byte) (boltItem, error) {
+	if len(v) < 8 {
+		return boltItem{}, errInvalidBoltItem
+	}
+
+	item := boltItem{expireAt: int64(binary.BigEndian.Uint64(v))}
+	v = v[8:]
+	count, n := binary.Uvarint(v)
+	if n <= 0 {
+		return boltItem{}, errInvalidBoltItem
+	}
+	v = v[n:]
+
+	for i := uint64(0); i < count; i++ {
+		size, n := binary.Uvarint(v)
+		if n <= 0 || uint64(len(v)-n) < size {
+			return boltItem{}, errInvalidBoltItem
+		}
+		item.tags = append(item.tags, string(v[n:n+int(size)]))
+		v = v[n+int(size):]
+	}
+
+	item.data = append([]byte{}, v...)
+	return item, nil
+}
+
+func boltExpired(v []byte, now int64) bool {
+	if len(v) < 8 {
+		return true
+	}
+	expireAt := int64(binary.BigEndian.Uint64(v))
+	return expireAt != 0 && expireAt <= now
+}
+
+func boltTagIndex(tag, key string) []byte {
+	return []byte(tag + "\x00" + key)
+}
+
+// boltTagKeys returns the keys indexed by tag, including expired ones.
+func boltTagKeys(tx *bolt.Tx, tag string) []string {
+	prefix := boltTagIndex(tag, "")
+	cursor := tx.Bucket(boltTagsBucket).Cursor()
+
+	var keys []string
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		keys = append(keys, string(k[len(prefix):]))
+	}
+	return keys
+}
+
+// getBoltItem returns the item of key which is not expired before now.
+func getBoltItem(tx *bolt.Tx, key string, now int64) (boltItem, bool, error) {
+	v := tx.Bucket(boltItemsBucket).Get([]byte(key))
+	if v == nil || boltExpired(v, now) {
+		return boltItem{}, false, nil
+	}
+
+	item, err := unmarshalBoltItem(v)
+	if err != nil {
+		return boltItem{}, false, err
+	}
+	return item, true, nil
+}
+
+func putBoltItem(tx *bolt.Tx, key string, item boltItem) error {
+	if _, err := removeBoltItem(tx, key); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(boltItemsBucket).Put([]byte(key), item.marshal()); err != nil {
+		return err
+	}
+	tags := tx.Bucket(boltTagsBucket)
+	for _, tag := range item.tags {
+		if err := tags.Put(boltTagIndex(tag, key), []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeBoltItem removes key and its tag indexes, expired or not.
+func removeBoltItem(tx *bolt.Tx, key string) (bool, error) {
+	items := tx.Bucket(boltItemsBucket)
+	v := items.Get([]byte(key))
+	if v == nil {
+		return false, nil
+	}
+
+	// an invalid item is removed as well, otherwise the key could never be written again
+	item, _ := unmarshalBoltItem(v)
+	tags := tx.Bucket(boltTagsBucket)
+	for _, tag := range item.tags {
+		if err := tags.Delete(boltTagIndex(tag, key)); err != nil {
+			return false, err
+		}
+	}
+	return true, items.Delete([]byte(key))
+}
+
+// sweepBolt removes all items expired before now.
+func sweepBolt(db *bolt.DB, now int64) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		var expired []string
+		cursor := tx.Bucket(boltItemsBucket).Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			if boltExpired(v, now) {
+				expired = append(expired, string(k))
+			}
+		}
+
+		for _, key := range expired {
+			if _, err := removeBoltItem(tx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eddieowens/opts"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestBolt(t *testing.T, errNotFound error, op ...opts.Opt[DriverOpts]) Cache {
+	t.Helper()
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "cache.db"), 0o600, &bolt.Options{Timeout: time.Second})
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	cache, err := NewBolt(db, errNotFound, op...)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = cache.(io.Closer).Close()
+	})
+	return cache
+}
+
+func TestBoltPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	db, err := bolt.Open(path, 0o600, nil)
+	assert.NoError(t, err)
+	cache, err := NewBolt(db, errors.New("not found"))
+	assert.NoError(t, err)
+	assert.NoError(t, cache.SetWithTagsCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", "abc", time.Minute, "admin"))
+	assert.NoError(t, db.Close())
+
+	db, err = bolt.Open(path, 0o600, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+	cache, err = NewBolt(db, errors.New("not found"))
+	assert.NoError(t, err)
+
+	var val string
+	assert.NoError(t, cache.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", &val))
+	assert.Equal(t, "abc", val)
+
+	keys, err := cache.GetTagKeysCtx(context.Background(), "admin")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"JWT_ADMIN_AUTH:1:abc"}, keys)
+}
+
+func TestBoltJanitor(t *testing.T) {
+	cache := newTestBolt(t, errors.New("not found"), WithCleanInterval(time.Millisecond*100))
+
+	err := cache.SetWithTagsCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", "abc", time.Millisecond*200, "admin")
+	assert.NoError(t, err)
+
+	db := cache.(*boltCache).db
+	assert.Eventually(t, func() bool {
+		var n int
+		_ = db.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket(boltItemsBucket).Stats().KeyN + tx.Bucket(boltTagsBucket).Stats().KeyN
+			return nil
+		})
+		return n == 0
+	}, time.Second*3, time.Millisecond*100)
+
+	janitor := cache.(*boltCache).janitor
+	assert.NoError(t, cache.(io.Closer).Close())
+	select {
+	case <-janitor.stop:
+	default:
+		t.Fatal("janitor is not stopped")
+	}
+	assert.NoError(t, cache.(io.Closer).Close())
+}
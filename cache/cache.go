@@ -16,6 +16,47 @@ type Cache interface {
 	// GetPrefixKeysCtx get prefix key, give prefix key return all matched key
 	GetPrefixKeysCtx(ctx context.Context, prefix string) ([]string, error)
 
+	// ScanPrefixCtx call fn with batches of keys matched prefix until fn returns false or all keys are scanned,
+	// batchSize is a hint of the batch length, 0 means the default 100
+	ScanPrefixCtx(ctx context.Context, prefix string, batchSize int, fn func(keys []string) bool) error
+
+	// DelPrefixCtx delete keys matched prefix batch by batch in a single scan, returns the number of keys deleted
+	DelPrefixCtx(ctx context.Context, prefix string, batchSize int) (int, error)
+
+	// TakeWithRefreshCtx take like TakeCtx, the value is kept for hardTTL but only fresh for softTTL,
+	// a stale value is returned at once while query reloads it in background, callers block only after hardTTL.
+	// The value is cached together with its freshness deadline, so read the key by TakeWithRefreshCtx only
+	TakeWithRefreshCtx(ctx context.Context, val any, key string, softTTL, hardTTL time.Duration, query func(val any) error) error
+
+	// IncrByCtx atomically add delta to the counter of key and return the new value, a missing key counts from 0,
+	// counters are encoded by the codec like other values, so GetCtx reads them into an integer, and keep their expire
+	IncrByCtx(ctx context.Context, key string, delta int64) (int64, error)
+
+	// DecrByCtx atomically subtract delta from the counter of key and return the new value
+	DecrByCtx(ctx context.Context, key string, delta int64) (int64, error)
+
+	// SetNXCtx set key only if it does not exist, zero expire means no expire, returns whether key is set
+	SetNXCtx(ctx context.Context, key string, val any, expire time.Duration) (bool, error)
+
+	// CompareAndSwapCtx set key to newVal only if its value equals oldVal, the expire is kept, returns whether key is swapped
+	CompareAndSwapCtx(ctx context.Context, key string, oldVal, newVal any) (bool, error)
+
 	// ExpireCtx set key expire
 	ExpireCtx(ctx context.Context, key string, expire time.Duration) error
+
+	// MGetCtx get values of keys into valsPtr, valsPtr must be a pointer to slice and is filled in the order of keys,
+	// missing keys are left zero value and returned as notFound instead of failing the whole batch
+	MGetCtx(ctx context.Context, keys []string, valsPtr any) (notFound []string, err error)
+
+	// MSetWithExpireCtx set all kvs with the same expire, zero expire means no expire
+	MSetWithExpireCtx(ctx context.Context, kvs map[string]any, expire time.Duration) error
+
+	// SetWithTagsCtx set key with expire and tag it, so it can be invalidated by InvalidateTagsCtx
+	SetWithTagsCtx(ctx context.Context, key string, val any, expire time.Duration, tags ...string) error
+
+	// GetTagKeysCtx get keys tagged with any of tags
+	GetTagKeysCtx(ctx context.Context, tags ...string) ([]string, error)
+
+	// InvalidateTagsCtx delete all keys tagged with any of tags
+	InvalidateTagsCtx(ctx context.Context, tags ...string) error
 }
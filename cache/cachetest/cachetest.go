@@ -0,0 +1,540 @@
+// Package cachetest checks that a cache.Cache implementation behaves the same as the built-in drivers.
+package cachetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jzero-io/jzero-contrib/cache"
+)
+
+// Factory creates an empty cache.Cache which returns errNotFound for missing keys.
+// fastForward moves the clock of the cache forward, nil means the suite sleeps instead.
+type Factory func(t *testing.T, errNotFound error) (c cache.Cache, fastForward func(d time.Duration))
+
+type (
+	suite struct {
+		cache       cache.Cache
+		errNotFound error
+		fastForward func(d time.Duration)
+	}
+
+	user struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	}
+)
+
+// RunConformance runs the conformance suite against the caches created by factory,
+// every case gets a new cache.
+func RunConformance(t *testing.T, factory Factory) {
+	t.Helper()
+
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, s suite)
+	}{
+		{"GetSet", testGetSet},
+		{"Del", testDel},
+		{"Expire", testExpire},
+		{"Take", testTake},
+		{"TakeWithExpire", testTakeWithExpire},
+		{"TakeWithRefresh", testTakeWithRefresh},
+		{"Concurrency", testConcurrency},
+		{"Prefix", testPrefix},
+		{"Batch", testBatch},
+		{"Tags", testTags},
+		{"Atomic", testAtomic},
+		{"ContextExpire", testContextExpire},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			errNotFound := errors.New("cachetest: not found")
+			cc, fastForward := factory(t, errNotFound)
+			if fastForward == nil {
+				fastForward = time.Sleep
+			}
+			c.fn(t, suite{
+				cache:       cc,
+				errNotFound: errNotFound,
+				fastForward: fastForward,
+			})
+		})
+	}
+}
+
+func testGetSet(t *testing.T, s suite) {
+	ctx := context.Background()
+
+	assert.NoError(t, s.cache.SetCtx(ctx, "cachetest:user:1", user{Id: 1, Name: "jzero"}))
+	var u user
+	assert.NoError(t, s.cache.GetCtx(ctx, "cachetest:user:1", &u))
+	assert.Equal(t, user{Id: 1, Name: "jzero"}, u)
+
+	assert.NoError(t, s.cache.Set("cachetest:user:1", user{Id: 1, Name: "contrib"}))
+	assert.NoError(t, s.cache.Get("cachetest:user:1", &u))
+	assert.Equal(t, user{Id: 1, Name: "contrib"}, u)
+
+	err := s.cache.GetCtx(ctx, "cachetest:user:2", &u)
+	assert.ErrorIs(t, err, s.errNotFound)
+	assert.True(t, s.cache.IsNotFound(err))
+	assert.False(t, s.cache.IsNotFound(nil))
+	assert.False(t, s.cache.IsNotFound(errors.New("cachetest: other")))
+}
+
+func testDel(t *testing.T, s suite) {
+	ctx := context.Background()
+
+	assert.NoError(t, s.cache.SetCtx(ctx, "cachetest:del:1", "1"))
+	assert.NoError(t, s.cache.SetCtx(ctx, "cachetest:del:2", "2"))
+
+	// missing keys are not errors
+	assert.NoError(t, s.cache.DelCtx(ctx, "cachetest:del:1", "cachetest:del:2", "cachetest:del:3"))
+	assert.NoError(t, s.cache.DelCtx(ctx))
+	assert.NoError(t, s.cache.Del("cachetest:del:3"))
+
+	var val string
+	assert.True(t, s.cache.IsNotFound(s.cache.GetCtx(ctx, "cachetest:del:1", &val)))
+	assert.True(t, s.cache.IsNotFound(s.cache.GetCtx(ctx, "cachetest:del:2", &val)))
+}
+
+func testExpire(t *testing.T, s suite) {
+	ctx := context.Background()
+
+	assert.NoError(t, s.cache.SetWithExpireCtx(ctx, "cachetest:expire:1", "1", time.Millisecond*200))
+	assert.NoError(t, s.cache.SetNoExpireCtx(ctx, "cachetest:expire:2", "2"))
+	assert.NoError(t, s.cache.ExpireCtx(ctx, "cachetest:expire:2", time.Millisecond*200))
+	assert.NoError(t, s.cache.SetNoExpireCtx(ctx, "cachetest:expire:3", "3"))
+	assert.NoError(t, s.cache.MSetWithExpireCtx(ctx, map[string]any{"cachetest:expire:4": "4"}, time.Millisecond*200))
+	assert.NoError(t, s.cache.SetWithTagsCtx(ctx, "cachetest:expire:5", "5", time.Millisecond*200, "cachetest"))
+	// expiring a missing key is not an error
+	assert.NoError(t, s.cache.ExpireCtx(ctx, "cachetest:expire:6", time.Millisecond*200))
+
+	var val string
+	assert.NoError(t, s.cache.GetCtx(ctx, "cachetest:expire:1", &val))
+	assert.Equal(t, "1", val)
+	assert.NoError(t, s.cache.GetCtx(ctx, "cachetest:expire:2", &val))
+	assert.Equal(t, "2", val)
+
+	s.fastForward(time.Millisecond * 300)
+
+	// expired keys are not found, they are neither read nor listed
+	for _, key := range []string{"cachetest:expire:1", "cachetest:expire:2", "cachetest:expire:4", "cachetest:expire:5", "cachetest:expire:6"} {
+		err := s.cache.GetCtx(ctx, key, &val)
+		assert.ErrorIs(t, err, s.errNotFound, key)
+	}
+	assert.NoError(t, s.cache.GetCtx(ctx, "cachetest:expire:3", &val))
+	assert.Equal(t, "3", val)
+
+	keys, err := s.cache.GetPrefixKeysCtx(ctx, "cachetest:expire:")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cachetest:expire:3"}, keys)
+
+	keys, err = s.cache.GetTagKeysCtx(ctx, "cachetest")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	var vals []string
+	notFound, err := s.cache.MGetCtx(ctx, []string{"cachetest:expire:1", "cachetest:expire:3"}, &vals)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cachetest:expire:1"}, notFound)
+	assert.Equal(t, []string{"", "3"}, vals)
+
+	// zero expire deletes the key
+	assert.NoError(t, s.cache.ExpireCtx(ctx, "cachetest:expire:3", 0))
+	assert.True(t, s.cache.IsNotFound(s.cache.GetCtx(ctx, "cachetest:expire:3", &val)))
+}
+
+func testTake(t *testing.T, s suite) {
+	ctx := context.Background()
+
+	var calls int
+	load := func(v any) error {
+		calls++
+		*v.(*user) = user{Id: 1, Name: "jzero"}
+		return nil
+	}
+
+	var u user
+	assert.NoError(t, s.cache.TakeCtx(ctx, &u, "cachetest:take:1", load))
+	assert.Equal(t, user{Id: 1, Name: "jzero"}, u)
+	u = user{}
+	assert.NoError(t, s.cache.Take(&u, "cachetest:take:1", load))
+	assert.Equal(t, user{Id: 1, Name: "jzero"}, u)
+	assert.Equal(t, 1, calls)
+
+	u = user{}
+	assert.NoError(t, s.cache.GetCtx(ctx, "cachetest:take:1", &u))
+	assert.Equal(t, user{Id: 1, Name: "jzero"}, u)
+
+	// query errors are returned and never cached
+	errDB := errors.New("cachetest: db down")
+	calls = 0
+	fail := func(v any) error {
+		calls++
+		return errDB
+	}
+	assert.ErrorIs(t, s.cache.TakeCtx(ctx, &u, "cachetest:take:2", fail), errDB)
+	assert.ErrorIs(t, s.cache.TakeCtx(ctx, &u, "cachetest:take:2", fail), errDB)
+	assert.Equal(t, 2, calls)
+
+	// errNotFound is cached as a placeholder
+	calls = 0
+	none := func(v any) error {
+		calls++
+		return s.errNotFound
+	}
+	assert.ErrorIs(t, s.cache.TakeCtx(ctx, &u, "cachetest:take:3", none), s.errNotFound)
+	assert.ErrorIs(t, s.cache.TakeCtx(ctx, &u, "cachetest:take:3", none), s.errNotFound)
+	assert.Equal(t, 1, calls)
+	assert.ErrorIs(t, s.cache.GetCtx(ctx, "cachetest:take:3", &u), s.errNotFound)
+
+	var users []user
+	notFound, err := s.cache.MGetCtx(ctx, []string{"cachetest:take:3"}, &users)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cachetest:take:3"}, notFound)
+
+	// a real value replaces the placeholder
+	assert.NoError(t, s.cache.SetCtx(ctx, "cachetest:take:3", user{Id: 3}))
+	assert.NoError(t, s.cache.TakeCtx(ctx, &u, "cachetest:take:3", none))
+	assert.Equal(t, user{Id: 3}, u)
+	assert.Equal(t, 1, calls)
+}
+
+func testTakeWithExpire(t *testing.T, s suite) {
+	ctx := context.Background()
+
+	var calls int
+	load := func(v any, expire time.Duration) error {
+		calls++
+		// 0 means no expiry, the default of syncMap
+		assert.GreaterOrEqual(t, expire, time.Duration(0))
+		*v.(*string) = "1"
+		return nil
+	}
+
+	var val string
+	assert.NoError(t, s.cache.TakeWithExpireCtx(ctx, &val, "cachetest:take:1", load))
+	assert.Equal(t, "1", val)
+	val = ""
+	assert.NoError(t, s.cache.TakeWithExpire(&val, "cachetest:take:1", load))
+	assert.Equal(t, "1", val)
+	assert.Equal(t, 1, calls)
+
+	val = ""
+	assert.NoError(t, s.cache.GetCtx(ctx, "cachetest:take:1", &val))
+	assert.Equal(t, "1", val)
+}
+
+func testTakeWithRefresh(t *testing.T, s suite) {
+	ctx := context.Background()
+
+	var calls int32
+	load := func(v any) error {
+		*v.(*int32) = atomic.AddInt32(&calls, 1)
+		return nil
+	}
+
+	var val int32
+	err := s.cache.TakeWithRefreshCtx(ctx, &val, "cachetest:refresh:1", time.Millisecond*200, time.Millisecond*100, load)
+	assert.ErrorIs(t, err, cache.ErrInvalidRefreshTTL)
+
+	assert.NoError(t, s.cache.TakeWithRefreshCtx(ctx, &val, "cachetest:refresh:1", time.Millisecond*200, time.Millisecond*500, load))
+	assert.Equal(t, int32(1), val)
+	assert.NoError(t, s.cache.TakeWithRefreshCtx(ctx, &val, "cachetest:refresh:1", time.Millisecond*200, time.Millisecond*500, load))
+	assert.Equal(t, int32(1), val)
+
+	// past softTTL, the stale value is served while reloading in background,
+	// freshness follows the clock of the caller while the expiry follows the cache
+	time.Sleep(time.Millisecond * 300)
+	assert.NoError(t, s.cache.TakeWithRefreshCtx(ctx, &val, "cachetest:refresh:1", time.Millisecond*200, time.Millisecond*500, load))
+	assert.Equal(t, int32(1), val)
+	assert.Eventually(t, func() bool {
+		var v int32
+		return s.cache.TakeWithRefreshCtx(ctx, &v, "cachetest:refresh:1", time.Millisecond*200, time.Millisecond*500, load) == nil && v >= 2
+	}, time.Second, time.Millisecond*10)
+
+	// past hardTTL, callers block on loading
+	s.fastForward(time.Millisecond * 600)
+	assert.NoError(t, s.cache.TakeWithRefreshCtx(ctx, &val, "cachetest:refresh:1", time.Millisecond*200, time.Millisecond*500, load))
+	assert.Equal(t, atomic.LoadInt32(&calls), val)
+	assert.Greater(t, val, int32(2))
+
+	// the value is cached with its freshness, no other keys are written
+	keys, err := s.cache.GetPrefixKeysCtx(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cachetest:refresh:1"}, keys)
+
+	var invalid int32
+	assert.ErrorIs(t, s.cache.TakeWithRefreshCtx(ctx, invalid, "cachetest:refresh:2", time.Second, time.Second, load), cache.ErrInvalidRefreshVal)
+	assert.ErrorIs(t, s.cache.TakeWithRefreshCtx(ctx, nil, "cachetest:refresh:2", time.Second, time.Second, load), cache.ErrInvalidRefreshVal)
+}
+
+func testConcurrency(t *testing.T, s suite) {
+	ctx := context.Background()
+
+	// concurrent takes of the same key query only once
+	var calls int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var val string
+			err := s.cache.TakeCtx(ctx, &val, "cachetest:concurrency:take", func(v any) error {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(time.Millisecond * 100)
+				*v.(*string) = "take"
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "take", val)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// concurrent writes and reads of the same key never fail except not found
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				assert.NoError(t, s.cache.SetCtx(ctx, "cachetest:concurrency:rw", "rw"))
+				var val string
+				if err := s.cache.GetCtx(ctx, "cachetest:concurrency:rw", &val); err != nil {
+					assert.ErrorIs(t, err, s.errNotFound)
+				} else {
+					assert.Equal(t, "rw", val)
+				}
+				assert.NoError(t, s.cache.DelCtx(ctx, "cachetest:concurrency:rw"))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func testPrefix(t *testing.T, s suite) {
+	ctx := context.Background()
+
+	prefixed := []string{"cachetest:prefix:1:a", "cachetest:prefix:1:b", "cachetest:prefix:1:c"}
+	for _, key := range append(prefixed, "cachetest:prefix:2:a") {
+		assert.NoError(t, s.cache.SetCtx(ctx, key, key))
+	}
+
+	keys, err := s.cache.GetPrefixKeysCtx(ctx, "cachetest:prefix:1:")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, prefixed, keys)
+
+	keys, err = s.cache.GetPrefixKeysCtx(ctx, "cachetest:none:")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	keys = nil
+	err = s.cache.ScanPrefixCtx(ctx, "cachetest:prefix:1:", 1, func(batch []string) bool {
+		assert.NotEmpty(t, batch)
+		keys = append(keys, batch...)
+		return true
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, prefixed, keys)
+
+	var batches int
+	err = s.cache.ScanPrefixCtx(ctx, "cachetest:prefix:1:", 1, func(batch []string) bool {
+		batches++
+		return false
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, batches)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = s.cache.ScanPrefixCtx(canceled, "cachetest:prefix:1:", 1, func(batch []string) bool {
+		return true
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// a single batch, emulators such as miniredis page SCAN by offset and skip the keys after a deleted batch
+	deleted, err := s.cache.DelPrefixCtx(ctx, "cachetest:prefix:1:", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+
+	keys, err = s.cache.GetPrefixKeysCtx(ctx, "cachetest:prefix:")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cachetest:prefix:2:a"}, keys)
+}
+
+func testBatch(t *testing.T, s suite) {
+	ctx := context.Background()
+
+	err := s.cache.MSetWithExpireCtx(ctx, map[string]any{
+		"cachetest:batch:1": user{Id: 1},
+		"cachetest:batch:2": user{Id: 2},
+	}, 0)
+	assert.NoError(t, err)
+
+	var users []user
+	notFound, err := s.cache.MGetCtx(ctx, []string{"cachetest:batch:2", "cachetest:batch:3", "cachetest:batch:1"}, &users)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cachetest:batch:3"}, notFound)
+	assert.Equal(t, []user{{Id: 2}, {}, {Id: 1}}, users)
+
+	notFound, err = s.cache.MGetCtx(ctx, nil, &users)
+	assert.NoError(t, err)
+	assert.Empty(t, notFound)
+	assert.Empty(t, users)
+
+	_, err = s.cache.MGetCtx(ctx, []string{"cachetest:batch:1"}, users)
+	assert.ErrorIs(t, err, cache.ErrInvalidBatchValues)
+}
+
+func testTags(t *testing.T, s suite) {
+	ctx := context.Background()
+
+	assert.NoError(t, s.cache.SetWithTagsCtx(ctx, "cachetest:user:42:profile", "profile", time.Minute, "user:42"))
+	assert.NoError(t, s.cache.SetWithTagsCtx(ctx, "cachetest:user:42:orders", "orders", 0, "user:42", "orders"))
+	assert.NoError(t, s.cache.SetWithTagsCtx(ctx, "cachetest:user:43:orders", "orders", time.Minute, "orders"))
+
+	keys, err := s.cache.GetTagKeysCtx(ctx, "user:42")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"cachetest:user:42:profile", "cachetest:user:42:orders"}, keys)
+
+	keys, err = s.cache.GetTagKeysCtx(ctx, "user:42", "orders")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"cachetest:user:42:profile", "cachetest:user:42:orders", "cachetest:user:43:orders"}, keys)
+
+	assert.NoError(t, s.cache.InvalidateTagsCtx(ctx, "user:42", "none"))
+
+	var val string
+	assert.True(t, s.cache.IsNotFound(s.cache.GetCtx(ctx, "cachetest:user:42:profile", &val)))
+	assert.True(t, s.cache.IsNotFound(s.cache.GetCtx(ctx, "cachetest:user:42:orders", &val)))
+	assert.NoError(t, s.cache.GetCtx(ctx, "cachetest:user:43:orders", &val))
+
+	keys, err = s.cache.GetTagKeysCtx(ctx, "orders")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cachetest:user:43:orders"}, keys)
+}
+
+func testAtomic(t *testing.T, s suite) {
+	ctx := context.Background()
+
+	// counters start from 0 and are readable as integers, whatever the codec of the driver is
+	n, err := s.cache.IncrByCtx(ctx, "cachetest:atomic:counter", 6)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+	var counter int64
+	assert.NoError(t, s.cache.GetCtx(ctx, "cachetest:atomic:counter", &counter))
+	assert.Equal(t, int64(6), counter)
+	n, err = s.cache.DecrByCtx(ctx, "cachetest:atomic:counter", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	n, err = s.cache.DecrByCtx(ctx, "cachetest:atomic:counter", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.NoError(t, s.cache.GetCtx(ctx, "cachetest:atomic:counter", &counter))
+	assert.Equal(t, int64(3), counter)
+
+	// counting a value which is not an integer fails
+	assert.NoError(t, s.cache.SetCtx(ctx, "cachetest:atomic:string", "a"))
+	_, err = s.cache.IncrByCtx(ctx, "cachetest:atomic:string", 1)
+	assert.Error(t, err)
+
+	// concurrent counting loses no update
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, err := s.cache.IncrByCtx(ctx, "cachetest:atomic:concurrency", 1)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	n, err = s.cache.IncrByCtx(ctx, "cachetest:atomic:concurrency", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), n)
+
+	// SetNX only sets missing keys
+	set, err := s.cache.SetNXCtx(ctx, "cachetest:atomic:nx", "1", time.Millisecond*200)
+	assert.NoError(t, err)
+	assert.True(t, set)
+	set, err = s.cache.SetNXCtx(ctx, "cachetest:atomic:nx", "2", time.Millisecond*200)
+	assert.NoError(t, err)
+	assert.False(t, set)
+	var val string
+	assert.NoError(t, s.cache.GetCtx(ctx, "cachetest:atomic:nx", &val))
+	assert.Equal(t, "1", val)
+
+	// CompareAndSwap only swaps the expected value
+	swapped, err := s.cache.CompareAndSwapCtx(ctx, "cachetest:atomic:cas", "a", "b")
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+	assert.NoError(t, s.cache.SetCtx(ctx, "cachetest:atomic:cas", "a"))
+	swapped, err = s.cache.CompareAndSwapCtx(ctx, "cachetest:atomic:cas", "b", "c")
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+	swapped, err = s.cache.CompareAndSwapCtx(ctx, "cachetest:atomic:cas", "a", "c")
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+	assert.NoError(t, s.cache.GetCtx(ctx, "cachetest:atomic:cas", &val))
+	assert.Equal(t, "c", val)
+
+	// counters and swapped values keep their expire
+	assert.NoError(t, s.cache.SetWithExpireCtx(ctx, "cachetest:atomic:cas:expire", "a", time.Millisecond*200))
+	swapped, err = s.cache.CompareAndSwapCtx(ctx, "cachetest:atomic:cas:expire", "a", "b")
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+	assert.NoError(t, s.cache.ExpireCtx(ctx, "cachetest:atomic:counter", time.Millisecond*200))
+	n, err = s.cache.IncrByCtx(ctx, "cachetest:atomic:counter", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+
+	s.fastForward(time.Millisecond * 300)
+
+	for _, key := range []string{"cachetest:atomic:counter", "cachetest:atomic:cas:expire"} {
+		assert.ErrorIs(t, s.cache.GetCtx(ctx, key, &val), s.errNotFound, key)
+	}
+	n, err = s.cache.IncrByCtx(ctx, "cachetest:atomic:counter", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	set, err = s.cache.SetNXCtx(ctx, "cachetest:atomic:nx", "2", 0)
+	assert.NoError(t, err)
+	assert.True(t, set)
+}
+
+func testContextExpire(t *testing.T, s suite) {
+	ctx := cache.WithExpire(context.Background(), time.Millisecond*200)
+
+	assert.NoError(t, s.cache.SetCtx(ctx, "cachetest:ctxexpire:set", "set"))
+	var val string
+	assert.NoError(t, s.cache.TakeCtx(ctx, &val, "cachetest:ctxexpire:take", func(v any) error {
+		*v.(*string) = "take"
+		return nil
+	}))
+	assert.NoError(t, s.cache.TakeWithExpireCtx(ctx, &val, "cachetest:ctxexpire:takewithexpire", func(v any, expire time.Duration) error {
+		// jittered around the expire of ctx
+		assert.InDelta(t, float64(time.Millisecond*200), float64(expire), float64(time.Millisecond*20))
+		*v.(*string) = "takewithexpire"
+		return nil
+	}))
+	// expires are not rounded to seconds
+	assert.NoError(t, s.cache.SetWithExpireCtx(context.Background(), "cachetest:ctxexpire:setwithexpire", "setwithexpire", time.Millisecond*200))
+	assert.NoError(t, s.cache.SetCtx(context.Background(), "cachetest:ctxexpire:default", "default"))
+
+	s.fastForward(time.Millisecond * 300)
+
+	for _, key := range []string{"cachetest:ctxexpire:set", "cachetest:ctxexpire:take", "cachetest:ctxexpire:takewithexpire", "cachetest:ctxexpire:setwithexpire"} {
+		assert.ErrorIs(t, s.cache.GetCtx(context.Background(), key, &val), s.errNotFound, key)
+	}
+	assert.NoError(t, s.cache.GetCtx(context.Background(), "cachetest:ctxexpire:default", &val))
+	assert.Equal(t, "default", val)
+}
@@ -0,0 +1,373 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/eddieowens/opts"
+	"github.com/zeromicro/go-zero/core/errorx"
+	"github.com/zeromicro/go-zero/core/hash"
+	zerocache "github.com/zeromicro/go-zero/core/stores/cache"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zeromicro/go-zero/core/syncx"
+)
+
+// cluster follows go-zero cache cluster, keys are sharded to nodes by consistent hash,
+// and the operations without a key such as prefix scans and tags are fanned out to every node.
+type cluster struct {
+	dispatcher  *hash.ConsistentHash
+	nodes       map[string]Cache
+	hosts       []string
+	errNotFound error
+}
+
+// NewCluster creates a Cache sharded across the redis nodes of c.
+// Nodes are hashed by host, so every replica with the same conf shards keys the same way.
+func NewCluster(c zerocache.CacheConf, errNotFound error, op ...opts.Opt[DriverOpts]) Cache {
+	if len(c) == 0 || zerocache.TotalWeights(c) <= 0 {
+		log.Fatal("no cache nodes")
+	}
+
+	o := newDriverOpts(op...)
+	barrier := syncx.NewSingleFlight()
+	st := zerocache.NewStat("redis-cache")
+
+	if len(c) == 1 {
+		return newRedisNode(redis.MustNewRedis(c[0].RedisConf), barrier, st, errNotFound, o)
+	}
+
+	cc := &cluster{
+		dispatcher:  hash.NewConsistentHash(),
+		nodes:       make(map[string]Cache, len(c)),
+		errNotFound: errNotFound,
+	}
+	for _, node := range c {
+		cc.nodes[node.Host] = newRedisNode(redis.MustNewRedis(node.RedisConf), barrier, st, errNotFound, o)
+		cc.hosts = append(cc.hosts, node.Host)
+		cc.dispatcher.AddWithWeight(node.Host, node.Weight)
+	}
+	return cc
+}
+
+func (cc *cluster) node(key string) (Cache, bool) {
+	host, ok := cc.dispatcher.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return cc.nodes[host.(string)], true
+}
+
+func (cc *cluster) ttlCtx(ctx context.Context, key string) (time.Duration, bool) {
+	c, ok := cc.node(key)
+	if !ok {
+		return 0, false
+	}
+	if t, ok := c.(ttlCache); ok {
+		return t.ttlCtx(ctx, key)
+	}
+	return 0, false
+}
+
+// group groups keys by their nodes.
+func (cc *cluster) group(keys []string) (map[Cache][]string, error) {
+	var be errorx.BatchError
+	nodes := make(map[Cache][]string)
+	for _, key := range keys {
+		c, ok := cc.node(key)
+		if !ok {
+			be.Add(fmt.Errorf("key %q not found", key))
+			continue
+		}
+		nodes[c] = append(nodes[c], key)
+	}
+	return nodes, be.Err()
+}
+
+func (cc *cluster) Del(keys ...string) error {
+	return cc.DelCtx(context.Background(), keys...)
+}
+
+func (cc *cluster) DelCtx(ctx context.Context, keys ...string) error {
+	nodes, err := cc.group(keys)
+
+	var be errorx.BatchError
+	be.Add(err)
+	for c, ks := range nodes {
+		be.Add(c.DelCtx(ctx, ks...))
+	}
+	return be.Err()
+}
+
+func (cc *cluster) Get(key string, val any) error {
+	return cc.GetCtx(context.Background(), key, val)
+}
+
+func (cc *cluster) GetCtx(ctx context.Context, key string, val any) error {
+	c, ok := cc.node(key)
+	if !ok {
+		return cc.errNotFound
+	}
+	return c.GetCtx(ctx, key, val)
+}
+
+func (cc *cluster) IsNotFound(err error) bool {
+	return errors.Is(err, cc.errNotFound)
+}
+
+func (cc *cluster) Set(key string, val any) error {
+	return cc.SetCtx(context.Background(), key, val)
+}
+
+func (cc *cluster) SetCtx(ctx context.Context, key string, val any) error {
+	c, ok := cc.node(key)
+	if !ok {
+		return cc.errNotFound
+	}
+	return c.SetCtx(ctx, key, val)
+}
+
+func (cc *cluster) SetWithExpire(key string, val any, expire time.Duration) error {
+	return cc.SetWithExpireCtx(context.Background(), key, val, expire)
+}
+
+func (cc *cluster) SetWithExpireCtx(ctx context.Context, key string, val any, expire time.Duration) error {
+	c, ok := cc.node(key)
+	if !ok {
+		return cc.errNotFound
+	}
+	return c.SetWithExpireCtx(ctx, key, val, expire)
+}
+
+func (cc *cluster) Take(val any, key string, query func(val any) error) error {
+	return cc.TakeCtx(context.Background(), val, key, query)
+}
+
+func (cc *cluster) TakeCtx(ctx context.Context, val any, key string, query func(val any) error) error {
+	c, ok := cc.node(key)
+	if !ok {
+		return cc.errNotFound
+	}
+	return c.TakeCtx(ctx, val, key, query)
+}
+
+func (cc *cluster) TakeWithExpire(val any, key string, query func(val any, expire time.Duration) error) error {
+	return cc.TakeWithExpireCtx(context.Background(), val, key, query)
+}
+
+func (cc *cluster) TakeWithExpireCtx(ctx context.Context, val any, key string, query func(val any, expire time.Duration) error) error {
+	c, ok := cc.node(key)
+	if !ok {
+		return cc.errNotFound
+	}
+	return c.TakeWithExpireCtx(ctx, val, key, query)
+}
+
+func (cc *cluster) TakeWithRefreshCtx(ctx context.Context, val any, key string, softTTL, hardTTL time.Duration, query func(val any) error) error {
+	c, ok := cc.node(key)
+	if !ok {
+		return cc.errNotFound
+	}
+	return c.TakeWithRefreshCtx(ctx, val, key, softTTL, hardTTL, query)
+}
+
+func (cc *cluster) IncrByCtx(ctx context.Context, key string, delta int64) (int64, error) {
+	c, ok := cc.node(key)
+	if !ok {
+		return 0, cc.errNotFound
+	}
+	return c.IncrByCtx(ctx, key, delta)
+}
+
+func (cc *cluster) DecrByCtx(ctx context.Context, key string, delta int64) (int64, error) {
+	c, ok := cc.node(key)
+	if !ok {
+		return 0, cc.errNotFound
+	}
+	return c.DecrByCtx(ctx, key, delta)
+}
+
+func (cc *cluster) SetNXCtx(ctx context.Context, key string, val any, expire time.Duration) (bool, error) {
+	c, ok := cc.node(key)
+	if !ok {
+		return false, cc.errNotFound
+	}
+	return c.SetNXCtx(ctx, key, val, expire)
+}
+
+func (cc *cluster) CompareAndSwapCtx(ctx context.Context, key string, oldVal, newVal any) (bool, error) {
+	c, ok := cc.node(key)
+	if !ok {
+		return false, cc.errNotFound
+	}
+	return c.CompareAndSwapCtx(ctx, key, oldVal, newVal)
+}
+
+func (cc *cluster) SetNoExpireCtx(ctx context.Context, key string, val any) error {
+	c, ok := cc.node(key)
+	if !ok {
+		return cc.errNotFound
+	}
+	return c.SetNoExpireCtx(ctx, key, val)
+}
+
+func (cc *cluster) ExpireCtx(ctx context.Context, key string, expire time.Duration) error {
+	c, ok := cc.node(key)
+	if !ok {
+		return cc.errNotFound
+	}
+	return c.ExpireCtx(ctx, key, expire)
+}
+
+func (cc *cluster) GetPrefixKeysCtx(ctx context.Context, prefix string) ([]string, error) {
+	var allKeys []string
+	err := cc.ScanPrefixCtx(ctx, prefix, defaultScanBatchSize, func(keys []string) bool {
+		allKeys = append(allKeys, keys...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allKeys, nil
+}
+
+// ScanPrefixCtx scans the nodes one by one, in the order of the conf.
+func (cc *cluster) ScanPrefixCtx(ctx context.Context, prefix string, batchSize int, fn func(keys []string) bool) error {
+	for _, host := range cc.hosts {
+		stopped := false
+		err := cc.nodes[host].ScanPrefixCtx(ctx, prefix, batchSize, func(keys []string) bool {
+			if !fn(keys) {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if err != nil || stopped {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cc *cluster) DelPrefixCtx(ctx context.Context, prefix string, batchSize int) (int, error) {
+	var deleted int
+	for _, host := range cc.hosts {
+		n, err := cc.nodes[host].DelPrefixCtx(ctx, prefix, batchSize)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+func (cc *cluster) MGetCtx(ctx context.Context, keys []string, valsPtr any) ([]string, error) {
+	vals, err := newBatchValues(valsPtr, len(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make(map[string][]int, len(keys))
+	for i, key := range keys {
+		indexes[key] = append(indexes[key], i)
+	}
+
+	nodes, err := cc.group(uniqueKeys(append([]string(nil), keys...)))
+	if err != nil {
+		return nil, err
+	}
+
+	var notFound []string
+	for c, ks := range nodes {
+		nodeValsPtr := vals.newLike()
+		nodeNotFound, err := c.MGetCtx(ctx, ks, nodeValsPtr)
+		if err != nil {
+			return nil, err
+		}
+		notFound = append(notFound, nodeNotFound...)
+
+		nodeVals := batchValuesOf(nodeValsPtr)
+		for j, key := range ks {
+			for _, i := range indexes[key] {
+				vals.set(i, nodeVals, j)
+			}
+		}
+	}
+
+	// keep notFound in the order of keys as the drivers do
+	missing := make(map[string]struct{}, len(notFound))
+	for _, key := range notFound {
+		missing[key] = struct{}{}
+	}
+	notFound = notFound[:0]
+	for _, key := range keys {
+		if _, ok := missing[key]; ok {
+			notFound = append(notFound, key)
+		}
+	}
+	return notFound, nil
+}
+
+func (cc *cluster) MSetWithExpireCtx(ctx context.Context, kvs map[string]any, expire time.Duration) error {
+	nodes := make(map[Cache]map[string]any)
+	for key, val := range kvs {
+		c, ok := cc.node(key)
+		if !ok {
+			return fmt.Errorf("key %q not found", key)
+		}
+		if nodes[c] == nil {
+			nodes[c] = make(map[string]any)
+		}
+		nodes[c][key] = val
+	}
+
+	for c, nodeKvs := range nodes {
+		if err := c.MSetWithExpireCtx(ctx, nodeKvs, expire); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetWithTagsCtx tags the key on its own node, so tags are fanned out to every node.
+func (cc *cluster) SetWithTagsCtx(ctx context.Context, key string, val any, expire time.Duration, tags ...string) error {
+	c, ok := cc.node(key)
+	if !ok {
+		return cc.errNotFound
+	}
+	return c.SetWithTagsCtx(ctx, key, val, expire, tags...)
+}
+
+func (cc *cluster) GetTagKeysCtx(ctx context.Context, tags ...string) ([]string, error) {
+	var allKeys []string
+	for _, host := range cc.hosts {
+		keys, err := cc.nodes[host].GetTagKeysCtx(ctx, tags...)
+		if err != nil {
+			return nil, err
+		}
+		allKeys = append(allKeys, keys...)
+	}
+	return uniqueKeys(allKeys), nil
+}
+
+func (cc *cluster) tagMembersCtx(ctx context.Context, tags ...string) ([]string, error) {
+	var allKeys []string
+	for _, host := range cc.hosts {
+		keys, err := tagMembers(ctx, cc.nodes[host], tags...)
+		if err != nil {
+			return nil, err
+		}
+		allKeys = append(allKeys, keys...)
+	}
+	return uniqueKeys(allKeys), nil
+}
+
+func (cc *cluster) InvalidateTagsCtx(ctx context.Context, tags ...string) error {
+	var be errorx.BatchError
+	for _, host := range cc.hosts {
+		be.Add(cc.nodes[host].InvalidateTagsCtx(ctx, tags...))
+	}
+	return be.Err()
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	zerocache "github.com/zeromicro/go-zero/core/stores/cache"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+func TestCluster(t *testing.T) {
+	r1, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r1.Close()
+	r2, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r2.Close()
+
+	cache := NewCluster(zerocache.CacheConf{
+		{RedisConf: redis.RedisConf{Host: r1.Addr(), Type: redis.NodeType}, Weight: 100},
+		{RedisConf: redis.RedisConf{Host: r2.Addr(), Type: redis.NodeType}, Weight: 100},
+	}, errors.New("not found"))
+	ctx := context.Background()
+
+	var keys []string
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("JWT_ADMIN_AUTH:1:%d", i)
+		keys = append(keys, key)
+		assert.NoError(t, cache.SetWithTagsCtx(ctx, key, i, 0, "admin"))
+	}
+	assert.NotEmpty(t, r1.Keys())
+	assert.NotEmpty(t, r2.Keys())
+
+	var val int
+	assert.NoError(t, cache.GetCtx(ctx, "JWT_ADMIN_AUTH:1:3", &val))
+	assert.Equal(t, 3, val)
+
+	prefixKeys, err := cache.GetPrefixKeysCtx(ctx, "JWT_ADMIN_AUTH:1:")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, keys, prefixKeys)
+
+	var vals []int
+	notFound, err := cache.MGetCtx(ctx, []string{"JWT_ADMIN_AUTH:1:1", "JWT_ADMIN_AUTH:1:missing", "JWT_ADMIN_AUTH:1:2"}, &vals)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 0, 2}, vals)
+	assert.Equal(t, []string{"JWT_ADMIN_AUTH:1:missing"}, notFound)
+
+	tagKeys, err := cache.GetTagKeysCtx(ctx, "admin")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, keys, tagKeys)
+
+	assert.NoError(t, cache.InvalidateTagsCtx(ctx, "admin", "missing"))
+	assert.True(t, cache.IsNotFound(cache.GetCtx(ctx, "JWT_ADMIN_AUTH:1:3", &val)))
+
+	assert.NoError(t, cache.MSetWithExpireCtx(ctx, map[string]any{
+		"JWT_ADMIN_AUTH:2:abc": "abc",
+		"JWT_ADMIN_AUTH:2:def": "def",
+		"JWT_ADMIN_AUTH:2:ghi": "ghi",
+	}, 0))
+	// a single batch, miniredis pages SCAN by offset and skips the keys after a deleted batch
+	deleted, err := cache.DelPrefixCtx(ctx, "JWT_ADMIN_AUTH:2:", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+
+	prefixKeys, err = cache.GetPrefixKeysCtx(ctx, "JWT_ADMIN_AUTH:")
+	assert.NoError(t, err)
+	assert.Empty(t, prefixKeys)
+}
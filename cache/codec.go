@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"io"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/zeromicro/go-zero/core/jsonx"
+)
+
+// Codec serializes cached values, Marshal must never return the not found placeholder "*".
+type Codec interface {
+	Marshal(val any) ([]byte, error)
+	Unmarshal(data []byte, val any) error
+}
+
+var (
+	// JsonCodec is the default codec, it is compatible with go-zero cache
+	JsonCodec Codec = jsonCodec{}
+	// GobCodec keeps go types such as time.Time and big integers, interface values must be registered by gob.Register
+	GobCodec Codec = gobCodec{}
+	// MsgpackCodec is a compact binary codec
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+var errInvalidCompressed = errors.New("cache: invalid compressed data")
+
+type (
+	jsonCodec struct{}
+
+	gobCodec struct{}
+
+	msgpackCodec struct{}
+
+	compressCodec struct {
+		codec     Codec
+		threshold int
+	}
+)
+
+func (jsonCodec) Marshal(val any) ([]byte, error) {
+	return jsonx.Marshal(val)
+}
+
+func (jsonCodec) Unmarshal(data []byte, val any) error {
+	return jsonx.Unmarshal(data, val)
+}
+
+func (gobCodec) Marshal(val any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(val); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, val any) error {
+	// gob omits zero fields, so a reused val would keep their stale values
+	if rv := reflect.ValueOf(val); rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv.Elem().SetZero()
+	}
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(val)
+}
+
+func (msgpackCodec) Marshal(val any) ([]byte, error) {
+	data, err := msgpack.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	// the compact form of 42 is "*", use the int64 form to avoid the not found placeholder
+	if string(data) == notFoundPlaceholder {
+		var buf bytes.Buffer
+		if err = msgpack.NewEncoder(&buf).EncodeInt64(int64(data[0])); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return data, nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, val any) error {
+	return msgpack.Unmarshal(data, val)
+}
+
+const (
+	rawFlag byte = iota
+	gzipFlag
+)
+
+// NewCompressCodec gzips values encoded by codec when they are larger than threshold bytes,
+// a flag byte is prepended to tell compressed values from raw ones.
+func NewCompressCodec(codec Codec, threshold int) Codec {
+	return compressCodec{
+		codec:     codec,
+		threshold: threshold,
+	}
+}
+
+func (c compressCodec) Marshal(val any) ([]byte, error) {
+	data, err := c.codec.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) <= c.threshold {
+		return append([]byte{rawFlag}, data...), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(gzipFlag)
+	w := gzip.NewWriter(&buf)
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c compressCodec) Unmarshal(data []byte, val any) error {
+	if len(data) == 0 {
+		return errInvalidCompressed
+	}
+
+	switch data[0] {
+	case rawFlag:
+		return c.codec.Unmarshal(data[1:], val)
+	case gzipFlag:
+		r, err := gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		raw, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return c.codec.Unmarshal(raw, val)
+	default:
+		return errInvalidCompressed
+	}
+}
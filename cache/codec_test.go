@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+type codecValue struct {
+	Id        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func TestCodec(t *testing.T) {
+	value := codecValue{
+		Id:        math.MaxUint64,
+		Name:      strings.Repeat("jaronnie", 100),
+		CreatedAt: time.Now(),
+	}
+
+	codecs := map[string]Codec{
+		"json":          JsonCodec,
+		"gob":           GobCodec,
+		"msgpack":       MsgpackCodec,
+		"compress":      NewCompressCodec(MsgpackCodec, 128),
+		"compress-skip": NewCompressCodec(JsonCodec, 4096),
+	}
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			data, err := codec.Marshal(value)
+			assert.NoError(t, err)
+
+			var got codecValue
+			assert.NoError(t, codec.Unmarshal(data, &got))
+			assert.Equal(t, value.Id, got.Id)
+			assert.Equal(t, value.Name, got.Name)
+			assert.True(t, value.CreatedAt.Equal(got.CreatedAt))
+
+			// zero fields must not keep the values of a reused val
+			data, err = codec.Marshal(codecValue{Id: 1})
+			assert.NoError(t, err)
+			assert.NoError(t, codec.Unmarshal(data, &got))
+			assert.Equal(t, codecValue{Id: 1}, got)
+
+			// must not collide with the not found placeholder
+			data, err = codec.Marshal(42)
+			assert.NoError(t, err)
+			assert.NotEqual(t, notFoundPlaceholder, string(data))
+
+			var n int
+			assert.NoError(t, codec.Unmarshal(data, &n))
+			assert.Equal(t, 42, n)
+		})
+	}
+
+	data, err := NewCompressCodec(JsonCodec, 128).Marshal(value)
+	assert.NoError(t, err)
+	assert.Equal(t, gzipFlag, data[0])
+}
+
+func TestDriverCodec(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	codec := NewCompressCodec(GobCodec, 64)
+	caches := map[string]Cache{
+		"syncMap":   NewSyncMap(errors.New("not found"), WithCodec(codec)),
+		"redisNode": NewRedisNodeWithOpts(redis.New(r.Addr()), errors.New("not found"), WithCodec(codec)),
+	}
+
+	value := codecValue{Id: math.MaxUint64, Name: strings.Repeat("jaronnie", 10), CreatedAt: time.Now()}
+	for name, cache := range caches {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, cache.SetCtx(context.Background(), "codec", value))
+
+			var got codecValue
+			assert.NoError(t, cache.GetCtx(context.Background(), "codec", &got))
+			assert.Equal(t, value.Id, got.Id)
+			assert.True(t, value.CreatedAt.Equal(got.CreatedAt))
+
+			var taken codecValue
+			assert.NoError(t, cache.TakeCtx(context.Background(), &taken, "codec", func(val any) error {
+				return errors.New("should be cached")
+			}))
+			assert.Equal(t, value.Name, taken.Name)
+		})
+	}
+}
+
+func TestRedisNodeRawCodec(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	value := codecValue{Id: math.MaxUint64, Name: "jaronnie", CreatedAt: time.Now()}
+	for name, codec := range map[string]Codec{"gob": GobCodec, "msgpack": MsgpackCodec} {
+		t.Run(name, func(t *testing.T) {
+			cache := NewRedisNodeWithOpts(redis.New(r.Addr()), errors.New("not found"), WithCodec(codec))
+			want, err := codec.Marshal(value)
+			assert.NoError(t, err)
+
+			// stored as the codec encodes it, the same bytes as syncMap keeps
+			assert.NoError(t, cache.SetCtx(context.Background(), name, value))
+			data, err := r.Get(name)
+			assert.NoError(t, err)
+			assert.Equal(t, string(want), data)
+
+			assert.NoError(t, cache.TakeCtx(context.Background(), &codecValue{}, name+":take", func(val any) error {
+				*val.(*codecValue) = value
+				return nil
+			}))
+			data, err = r.Get(name + ":take")
+			assert.NoError(t, err)
+			assert.Equal(t, string(want), data)
+		})
+	}
+}
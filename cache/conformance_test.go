@@ -0,0 +1,123 @@
+package cache_test
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	zerocache "github.com/zeromicro/go-zero/core/stores/cache"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/jzero-io/jzero-contrib/cache"
+	"github.com/jzero-io/jzero-contrib/cache/cachetest"
+)
+
+func runMiniredis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestConformance(t *testing.T) {
+	t.Run("SyncMap", func(t *testing.T) {
+		cachetest.RunConformance(t, func(t *testing.T, errNotFound error) (cache.Cache, func(d time.Duration)) {
+			return cache.NewSyncMap(errNotFound), nil
+		})
+	})
+
+	t.Run("RedisNode", func(t *testing.T) {
+		cachetest.RunConformance(t, func(t *testing.T, errNotFound error) (cache.Cache, func(d time.Duration)) {
+			r := runMiniredis(t)
+			return cache.NewRedisNode(redis.New(r.Addr()), errNotFound), r.FastForward
+		})
+	})
+
+	t.Run("Bolt", func(t *testing.T) {
+		cachetest.RunConformance(t, func(t *testing.T, errNotFound error) (cache.Cache, func(d time.Duration)) {
+			db, err := bolt.Open(filepath.Join(t.TempDir(), "cache.db"), 0o600, nil)
+			assert.NoError(t, err)
+			t.Cleanup(func() {
+				_ = db.Close()
+			})
+
+			c, err := cache.NewBolt(db, errNotFound)
+			assert.NoError(t, err)
+			t.Cleanup(func() {
+				_ = c.(io.Closer).Close()
+			})
+			return c, nil
+		})
+	})
+
+	// counters and every other value go through the codec, so the drivers are checked with non json codecs as well
+	t.Run("SyncMapMsgpack", func(t *testing.T) {
+		cachetest.RunConformance(t, func(t *testing.T, errNotFound error) (cache.Cache, func(d time.Duration)) {
+			return cache.NewSyncMap(errNotFound, cache.WithCodec(cache.MsgpackCodec)), nil
+		})
+	})
+
+	t.Run("RedisNodeCompressedMsgpack", func(t *testing.T) {
+		cachetest.RunConformance(t, func(t *testing.T, errNotFound error) (cache.Cache, func(d time.Duration)) {
+			r := runMiniredis(t)
+			codec := cache.NewCompressCodec(cache.MsgpackCodec, 0)
+			return cache.NewRedisNodeWithOpts(redis.New(r.Addr()), errNotFound, cache.WithCodec(codec)), r.FastForward
+		})
+	})
+
+	t.Run("RedisNodeGob", func(t *testing.T) {
+		cachetest.RunConformance(t, func(t *testing.T, errNotFound error) (cache.Cache, func(d time.Duration)) {
+			r := runMiniredis(t)
+			return cache.NewRedisNodeWithOpts(redis.New(r.Addr()), errNotFound, cache.WithCodec(cache.GobCodec)), r.FastForward
+		})
+	})
+
+	t.Run("BoltMsgpack", func(t *testing.T) {
+		cachetest.RunConformance(t, func(t *testing.T, errNotFound error) (cache.Cache, func(d time.Duration)) {
+			db, err := bolt.Open(filepath.Join(t.TempDir(), "cache.db"), 0o600, nil)
+			assert.NoError(t, err)
+			t.Cleanup(func() {
+				_ = db.Close()
+			})
+
+			c, err := cache.NewBolt(db, errNotFound, cache.WithCodec(cache.MsgpackCodec))
+			assert.NoError(t, err)
+			t.Cleanup(func() {
+				_ = c.(io.Closer).Close()
+			})
+			return c, nil
+		})
+	})
+
+	t.Run("Cluster", func(t *testing.T) {
+		cachetest.RunConformance(t, func(t *testing.T, errNotFound error) (cache.Cache, func(d time.Duration)) {
+			r1, r2 := runMiniredis(t), runMiniredis(t)
+			c := cache.NewCluster(zerocache.CacheConf{
+				{RedisConf: redis.RedisConf{Host: r1.Addr(), Type: redis.NodeType}, Weight: 100},
+				{RedisConf: redis.RedisConf{Host: r2.Addr(), Type: redis.NodeType}, Weight: 100},
+			}, errNotFound)
+			return c, func(d time.Duration) {
+				r1.FastForward(d)
+				r2.FastForward(d)
+			}
+		})
+	})
+
+	t.Run("TwoLevel", func(t *testing.T) {
+		cachetest.RunConformance(t, func(t *testing.T, errNotFound error) (cache.Cache, func(d time.Duration)) {
+			r := runMiniredis(t)
+			c := cache.NewTwoLevel(cache.NewSyncMap(errNotFound), cache.NewRedisNode(redis.New(r.Addr()), errNotFound))
+			// l1 follows the real clock
+			return c, func(d time.Duration) {
+				r.FastForward(d)
+				time.Sleep(d)
+			}
+		})
+	})
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"errors"
+	"math"
+
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+// ErrNotInteger is returned by IncrByCtx and DecrByCtx of local drivers when the value is not a counter,
+// redis returns its own error instead.
+var ErrNotInteger = errors.New("cache: value is not an integer or out of range")
+
+// casScript sets KEYS[1] to ARGV[2] only if its value is ARGV[1], the ttl of KEYS[1] is kept.
+var casScript = redis.NewScript(`if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+    return 0
+end
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl > 0 then
+    redis.call("SET", KEYS[1], ARGV[2], "PX", ttl)
+else
+    redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1`)
+
+// incrBy adds delta to the counter encoded by codec in data, nil data counts from 0.
+// Counters are encoded like other values, so GetCtx reads them, JsonCodec encodes them as decimal strings
+// as redis stores them.
+func incrBy(codec Codec, data []byte, delta int64) ([]byte, int64, error) {
+	var val int64
+	if data != nil {
+		if err := codec.Unmarshal(data, &val); err != nil {
+			return nil, 0, ErrNotInteger
+		}
+	}
+
+	val, err := add(val, delta)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err = codec.Marshal(val)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, val, nil
+}
+
+// add returns val + delta, ErrNotInteger if it overflows.
+func add(val, delta int64) (int64, error) {
+	if (delta > 0 && val > math.MaxInt64-delta) || (delta < 0 && val < math.MinInt64-delta) {
+		return 0, ErrNotInteger
+	}
+	return val + delta, nil
+}
+
+// negate negates delta of DecrByCtx, math.MinInt64 has no positive counterpart.
+func negate(delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, ErrNotInteger
+	}
+	return -delta, nil
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrBy(t *testing.T) {
+	data, val, err := incrBy(JsonCodec, nil, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), val)
+	assert.Equal(t, "2", string(data))
+
+	data, val, err = incrBy(JsonCodec, data, -5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-3), val)
+	assert.Equal(t, "-3", string(data))
+
+	_, _, err = incrBy(JsonCodec, []byte(`"a"`), 1)
+	assert.ErrorIs(t, err, ErrNotInteger)
+	_, _, err = incrBy(JsonCodec, []byte("9223372036854775807"), 1)
+	assert.ErrorIs(t, err, ErrNotInteger)
+	_, _, err = incrBy(JsonCodec, []byte("-9223372036854775808"), -1)
+	assert.ErrorIs(t, err, ErrNotInteger)
+
+	// counters are encoded by the codec, so GetCtx decodes them
+	data, val, err = incrBy(MsgpackCodec, nil, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), val)
+	var decoded int64
+	assert.NoError(t, MsgpackCodec.Unmarshal(data, &decoded))
+	assert.Equal(t, int64(6), decoded)
+	_, val, err = incrBy(MsgpackCodec, data, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), val)
+
+	_, err = negate(math.MinInt64)
+	assert.ErrorIs(t, err, ErrNotInteger)
+}
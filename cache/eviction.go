@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+type EvictionPolicy string
+
+const (
+	// LRU evicts the least recently used entry
+	LRU EvictionPolicy = "lru"
+	// LFU evicts the least frequently used entry, ties are broken by recency
+	LFU EvictionPolicy = "lfu"
+)
+
+// evictor tracks key usage and chooses which key to drop when the storage is full.
+type evictor interface {
+	add(key string)
+	touch(key string)
+	remove(key string)
+	victim() (string, bool)
+}
+
+func newEvictor(policy EvictionPolicy) evictor {
+	if policy == LFU {
+		return newLfuEvictor()
+	}
+	return newLruEvictor()
+}
+
+type lruEvictor struct {
+	ll    *list.List
+	elems map[string]*list.Element
+}
+
+func newLruEvictor() *lruEvictor {
+	return &lruEvictor{
+		ll:    list.New(),
+		elems: make(map[string]*list.Element),
+	}
+}
+
+func (e *lruEvictor) add(key string) {
+	if elem, ok := e.elems[key]; ok {
+		e.ll.MoveToFront(elem)
+		return
+	}
+	e.elems[key] = e.ll.PushFront(key)
+}
+
+func (e *lruEvictor) touch(key string) {
+	if elem, ok := e.elems[key]; ok {
+		e.ll.MoveToFront(elem)
+	}
+}
+
+func (e *lruEvictor) remove(key string) {
+	if elem, ok := e.elems[key]; ok {
+		e.ll.Remove(elem)
+		delete(e.elems, key)
+	}
+}
+
+func (e *lruEvictor) victim() (string, bool) {
+	elem := e.ll.Back()
+	if elem == nil {
+		return "", false
+	}
+	return elem.Value.(string), true
+}
+
+type (
+	lfuEntry struct {
+		key   string
+		freq  int64
+		tick  int64
+		index int
+	}
+
+	lfuHeap []*lfuEntry
+
+	lfuEvictor struct {
+		tick    int64
+		entries map[string]*lfuEntry
+		h       lfuHeap
+	}
+)
+
+func (h lfuHeap) Len() int {
+	return len(h)
+}
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq == h[j].freq {
+		return h[i].tick < h[j].tick
+	}
+	return h[i].freq < h[j].freq
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	entry := x.(*lfuEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return entry
+}
+
+func newLfuEvictor() *lfuEvictor {
+	return &lfuEvictor{
+		entries: make(map[string]*lfuEntry),
+	}
+}
+
+func (e *lfuEvictor) add(key string) {
+	if _, ok := e.entries[key]; ok {
+		e.touch(key)
+		return
+	}
+	e.tick++
+	entry := &lfuEntry{key: key, freq: 1, tick: e.tick}
+	e.entries[key] = entry
+	heap.Push(&e.h, entry)
+}
+
+func (e *lfuEvictor) touch(key string) {
+	entry, ok := e.entries[key]
+	if !ok {
+		return
+	}
+	e.tick++
+	entry.freq++
+	entry.tick = e.tick
+	heap.Fix(&e.h, entry.index)
+}
+
+func (e *lfuEvictor) remove(key string) {
+	entry, ok := e.entries[key]
+	if !ok {
+		return
+	}
+	heap.Remove(&e.h, entry.index)
+	delete(e.entries, key)
+}
+
+func (e *lfuEvictor) victim() (string, bool) {
+	if len(e.h) == 0 {
+		return "", false
+	}
+	return e.h[0].key, true
+}
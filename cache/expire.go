@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+type expireKey struct{}
+
+// LegacyExpireKey is the untyped ctx key which earlier versions read the expire of TakeWithExpireCtx from,
+// it is still read by every driver when ctx carries no expire by WithExpire.
+//
+// Deprecated: Use WithExpire instead, context.WithValue with a string key collides with other packages.
+const LegacyExpireKey = "expire"
+
+// WithExpire returns a copy of ctx which makes SetCtx, TakeCtx and TakeWithExpireCtx of every driver
+// cache values with expire instead of the default expiry of the driver.
+func WithExpire(ctx context.Context, expire time.Duration) context.Context {
+	return context.WithValue(ctx, expireKey{}, expire)
+}
+
+// expireFrom returns the expire carried by ctx, or def if ctx carries no positive one.
+func expireFrom(ctx context.Context, def time.Duration) time.Duration {
+	if expire, ok := ctxExpire(ctx); ok {
+		return expire
+	}
+	return def
+}
+
+// ctxExpire returns the expire carried by ctx, false if ctx carries no positive one.
+func ctxExpire(ctx context.Context) (time.Duration, bool) {
+	expire, ok := ctx.Value(expireKey{}).(time.Duration)
+	if !ok {
+		expire, ok = ctx.Value(LegacyExpireKey).(time.Duration)
+	}
+	return expire, ok && expire > 0
+}
+
+// expireAt returns the expire unix nano of expire from now, 0 means no expire.
+func expireAt(expire time.Duration) int64 {
+	if expire <= 0 {
+		return 0
+	}
+	return time.Now().Add(expire).UnixNano()
+}
+
+// remaining returns the ttl left until expireAt unix nano, 0 means no expire, false if it is expired.
+func remaining(expireAt int64) (time.Duration, bool) {
+	if expireAt == 0 {
+		return 0, true
+	}
+	ttl := time.Until(time.Unix(0, expireAt))
+	return ttl, ttl > 0
+}
+
+// milliseconds rounds expire up to milliseconds, the precision of redis PX and PEXPIRE.
+func milliseconds(expire time.Duration) int64 {
+	return int64((expire + time.Millisecond - 1) / time.Millisecond)
+}
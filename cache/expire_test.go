@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpireFrom(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, time.Minute, expireFrom(ctx, time.Minute))
+	assert.Equal(t, time.Second, expireFrom(WithExpire(ctx, time.Second), time.Minute))
+	assert.Equal(t, time.Minute, expireFrom(WithExpire(ctx, 0), time.Minute))
+	// the untyped key of earlier versions is still honoured, WithExpire takes precedence
+	legacy := context.WithValue(ctx, LegacyExpireKey, time.Second) //nolint:staticcheck
+	assert.Equal(t, time.Second, expireFrom(legacy, time.Minute))
+	assert.Equal(t, time.Hour, expireFrom(WithExpire(legacy, time.Hour), time.Minute))
+}
+
+func TestMilliseconds(t *testing.T) {
+	assert.Equal(t, int64(0), milliseconds(0))
+	assert.Equal(t, int64(1), milliseconds(time.Microsecond))
+	assert.Equal(t, int64(1500), milliseconds(time.Millisecond*1500))
+	assert.Equal(t, int64(1501), milliseconds(time.Millisecond*1500+time.Nanosecond))
+}
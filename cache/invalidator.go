@@ -0,0 +1,367 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eddieowens/opts"
+	red "github.com/redis/go-redis/v9"
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zeromicro/go-zero/core/stringx"
+)
+
+const defaultInvalidateChannel = "jzero:cache:invalidate"
+
+type (
+	InvalidatorOpts struct {
+		// Channel is the redis channel used to broadcast invalidated keys.
+		Channel string
+
+		// TLS must be set when rds is connected with tls, go-zero does not expose it.
+		// The server certificate is verified against the system roots unless TLSConfig or InsecureSkipVerify is set.
+		TLS bool
+
+		// TLSConfig is the tls config of the subscription, such as the RootCAs of a private CA, it implies TLS.
+		TLSConfig *tls.Config
+
+		// InsecureSkipVerify skips verifying the server certificate, as go-zero does for redis with tls, it implies TLS.
+		// Only use it when the network to redis is trusted.
+		InsecureSkipVerify bool
+	}
+
+	// Invalidator broadcasts deleted/updated keys over redis pub/sub,
+	// every instance evicts the keys from its registered local caches.
+	Invalidator struct {
+		id      string
+		rds     *redis.Redis
+		client  red.UniversalClient
+		pubsub  *red.PubSub
+		options InvalidatorOpts
+
+		lock   sync.RWMutex
+		caches []Cache
+	}
+
+	invalidateMessage struct {
+		From string   `json:"from"`
+		Keys []string `json:"keys"`
+		// Tags are invalidated by every local cache, which only knows the tags of its own writes
+		Tags []string `json:"tags,omitempty"`
+		// Prefix is deleted by every local cache, which only knows the keys of its own writes
+		// nil means no prefix, an empty prefix matches every key
+		Prefix *string `json:"prefix,omitempty"`
+	}
+
+	// invalidated publishes every write of local, so the same keys are evicted on other instances.
+	invalidated struct {
+		Cache
+		invalidator *Invalidator
+	}
+)
+
+func (opts InvalidatorOpts) DefaultOptions() InvalidatorOpts {
+	return InvalidatorOpts{
+		Channel: defaultInvalidateChannel,
+	}
+}
+
+func WithInvalidateChannel(channel string) opts.Opt[InvalidatorOpts] {
+	return func(o *InvalidatorOpts) {
+		o.Channel = channel
+	}
+}
+
+func WithInvalidateTLS(tls bool) opts.Opt[InvalidatorOpts] {
+	return func(o *InvalidatorOpts) {
+		o.TLS = tls
+	}
+}
+
+func WithInvalidateTLSConfig(config *tls.Config) opts.Opt[InvalidatorOpts] {
+	return func(o *InvalidatorOpts) {
+		o.TLSConfig = config
+	}
+}
+
+func WithInvalidateInsecureSkipVerify(skip bool) opts.Opt[InvalidatorOpts] {
+	return func(o *InvalidatorOpts) {
+		o.InsecureSkipVerify = skip
+	}
+}
+
+// NewInvalidator subscribes the invalidate channel on rds, it returns after the subscription is confirmed.
+func NewInvalidator(rds *redis.Redis, op ...opts.Opt[InvalidatorOpts]) (*Invalidator, error) {
+	o := opts.DefaultApply(op...)
+
+	tlsConfig := o.tlsConfig()
+
+	var client red.UniversalClient
+	if rds.Type == redis.ClusterType {
+		client = red.NewClusterClient(&red.ClusterOptions{
+			Addrs:     strings.Split(rds.Addr, ","),
+			Username:  rds.User,
+			Password:  rds.Pass,
+			TLSConfig: tlsConfig,
+		})
+	} else {
+		client = red.NewClient(&red.Options{
+			Addr:      rds.Addr,
+			Username:  rds.User,
+			Password:  rds.Pass,
+			TLSConfig: tlsConfig,
+		})
+	}
+
+	pubsub := client.Subscribe(context.Background(), o.Channel)
+	if _, err := pubsub.Receive(context.Background()); err != nil {
+		_ = pubsub.Close()
+		_ = client.Close()
+		return nil, err
+	}
+
+	inv := &Invalidator{
+		id:      stringx.Randn(16),
+		rds:     rds,
+		client:  client,
+		pubsub:  pubsub,
+		options: o,
+	}
+	go inv.listen()
+
+	return inv, nil
+}
+
+// tlsConfig returns the tls config of the subscription, nil if tls is not enabled.
+func (o InvalidatorOpts) tlsConfig() *tls.Config {
+	var config *tls.Config
+	switch {
+	case o.TLSConfig != nil:
+		config = o.TLSConfig.Clone()
+	case o.TLS || o.InsecureSkipVerify:
+		config = &tls.Config{MinVersion: tls.VersionTLS12}
+	default:
+		return nil
+	}
+	if o.InsecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+	return config
+}
+
+// Register adds local caches which evict the keys published by other instances.
+func (inv *Invalidator) Register(caches ...Cache) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+
+	inv.caches = append(inv.caches, caches...)
+}
+
+// PublishCtx tells other instances to evict keys from their local caches.
+func (inv *Invalidator) PublishCtx(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return inv.publish(ctx, invalidateMessage{Keys: keys})
+}
+
+// PublishTagsCtx tells other instances to invalidate tags in their local caches.
+func (inv *Invalidator) PublishTagsCtx(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	return inv.publish(ctx, invalidateMessage{Tags: tags})
+}
+
+// PublishPrefixCtx tells other instances to delete the keys with prefix from their local caches.
+func (inv *Invalidator) PublishPrefixCtx(ctx context.Context, prefix string) error {
+	return inv.publish(ctx, invalidateMessage{Prefix: &prefix})
+}
+
+func (inv *Invalidator) publish(ctx context.Context, im invalidateMessage) error {
+	im.From = inv.id
+	data, err := json.Marshal(im)
+	if err != nil {
+		return err
+	}
+	_, err = inv.rds.PublishCtx(ctx, inv.options.Channel, string(data))
+	return err
+}
+
+func (inv *Invalidator) Close() error {
+	if err := inv.pubsub.Close(); err != nil {
+		return err
+	}
+	return inv.client.Close()
+}
+
+func (inv *Invalidator) listen() {
+	for msg := range inv.pubsub.Channel() {
+		var im invalidateMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &im); err != nil {
+			logx.Errorf("invalid cache invalidate message: %s, error: %v", msg.Payload, err)
+			continue
+		}
+		// own writes are already applied to the local caches
+		if im.From == inv.id {
+			continue
+		}
+		inv.evict(im)
+	}
+}
+
+func (inv *Invalidator) evict(im invalidateMessage) {
+	inv.lock.RLock()
+	defer inv.lock.RUnlock()
+
+	ctx := context.Background()
+	for _, c := range inv.caches {
+		for _, key := range im.Keys {
+			// the key may not be cached locally at all
+			_ = c.DelCtx(ctx, key)
+		}
+		if len(im.Tags) > 0 {
+			if err := c.InvalidateTagsCtx(ctx, im.Tags...); err != nil {
+				logx.Errorf("invalidate cache tags: %v, error: %v", im.Tags, err)
+			}
+		}
+		if im.Prefix != nil {
+			if _, err := c.DelPrefixCtx(ctx, *im.Prefix, 0); err != nil {
+				logx.Errorf("delete cache prefix: %s, error: %v", *im.Prefix, err)
+			}
+		}
+	}
+}
+
+// NewInvalidated wraps local so that its writes are published through invalidator,
+// local is registered to evict the keys written by other instances.
+func NewInvalidated(local Cache, invalidator *Invalidator) Cache {
+	invalidator.Register(local)
+	return &invalidated{
+		Cache:       local,
+		invalidator: invalidator,
+	}
+}
+
+func (c *invalidated) ExpireCtx(ctx context.Context, key string, expire time.Duration) error {
+	if err := c.Cache.ExpireCtx(ctx, key, expire); err != nil {
+		return err
+	}
+	return c.invalidator.PublishCtx(ctx, key)
+}
+
+func (c *invalidated) SetNoExpireCtx(ctx context.Context, key string, val any) error {
+	if err := c.Cache.SetNoExpireCtx(ctx, key, val); err != nil {
+		return err
+	}
+	return c.invalidator.PublishCtx(ctx, key)
+}
+
+func (c *invalidated) IncrByCtx(ctx context.Context, key string, delta int64) (int64, error) {
+	val, err := c.Cache.IncrByCtx(ctx, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	return val, c.invalidator.PublishCtx(ctx, key)
+}
+
+func (c *invalidated) DecrByCtx(ctx context.Context, key string, delta int64) (int64, error) {
+	val, err := c.Cache.DecrByCtx(ctx, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	return val, c.invalidator.PublishCtx(ctx, key)
+}
+
+func (c *invalidated) SetNXCtx(ctx context.Context, key string, val any, expire time.Duration) (bool, error) {
+	set, err := c.Cache.SetNXCtx(ctx, key, val, expire)
+	if err != nil || !set {
+		return set, err
+	}
+	return true, c.invalidator.PublishCtx(ctx, key)
+}
+
+func (c *invalidated) CompareAndSwapCtx(ctx context.Context, key string, oldVal, newVal any) (bool, error) {
+	swapped, err := c.Cache.CompareAndSwapCtx(ctx, key, oldVal, newVal)
+	if err != nil || !swapped {
+		return swapped, err
+	}
+	return true, c.invalidator.PublishCtx(ctx, key)
+}
+
+func (c *invalidated) Del(keys ...string) error {
+	return c.DelCtx(context.Background(), keys...)
+}
+
+func (c *invalidated) DelCtx(ctx context.Context, keys ...string) error {
+	// other instances may still hold the keys even if they are missing here
+	if err := c.Cache.DelCtx(ctx, keys...); err != nil && !c.IsNotFound(err) {
+		return err
+	}
+	return c.invalidator.PublishCtx(ctx, keys...)
+}
+
+func (c *invalidated) Set(key string, val any) error {
+	return c.SetCtx(context.Background(), key, val)
+}
+
+func (c *invalidated) SetCtx(ctx context.Context, key string, val any) error {
+	if err := c.Cache.SetCtx(ctx, key, val); err != nil {
+		return err
+	}
+	return c.invalidator.PublishCtx(ctx, key)
+}
+
+func (c *invalidated) SetWithExpire(key string, val any, expire time.Duration) error {
+	return c.SetWithExpireCtx(context.Background(), key, val, expire)
+}
+
+func (c *invalidated) SetWithExpireCtx(ctx context.Context, key string, val any, expire time.Duration) error {
+	if err := c.Cache.SetWithExpireCtx(ctx, key, val, expire); err != nil {
+		return err
+	}
+	return c.invalidator.PublishCtx(ctx, key)
+}
+
+func (c *invalidated) MSetWithExpireCtx(ctx context.Context, kvs map[string]any, expire time.Duration) error {
+	if err := c.Cache.MSetWithExpireCtx(ctx, kvs, expire); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	return c.invalidator.PublishCtx(ctx, keys...)
+}
+
+func (c *invalidated) SetWithTagsCtx(ctx context.Context, key string, val any, expire time.Duration, tags ...string) error {
+	if err := c.Cache.SetWithTagsCtx(ctx, key, val, expire, tags...); err != nil {
+		return err
+	}
+	return c.invalidator.PublishCtx(ctx, key)
+}
+
+// InvalidateTagsCtx publishes tags rather than the local keys of tags,
+// since the local tag index only knows the keys written by this instance.
+func (c *invalidated) InvalidateTagsCtx(ctx context.Context, tags ...string) error {
+	if err := c.Cache.InvalidateTagsCtx(ctx, tags...); err != nil {
+		return err
+	}
+	return c.invalidator.PublishTagsCtx(ctx, tags...)
+}
+
+// DelPrefixCtx publishes prefix rather than the local keys of prefix,
+// since the local cache only knows the keys written by this instance.
+func (c *invalidated) DelPrefixCtx(ctx context.Context, prefix string, batchSize int) (int, error) {
+	n, err := c.Cache.DelPrefixCtx(ctx, prefix, batchSize)
+	if err != nil {
+		return n, err
+	}
+	return n, c.invalidator.PublishPrefixCtx(ctx, prefix)
+}
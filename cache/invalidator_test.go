@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/eddieowens/opts"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+func TestInvalidator(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	rds := redis.New(r.Addr())
+	errNotFound := errors.New("not found")
+
+	// two replicas, each holds its own local cache
+	inv1, err := NewInvalidator(rds)
+	assert.NoError(t, err)
+	defer inv1.Close()
+	inv2, err := NewInvalidator(rds)
+	assert.NoError(t, err)
+	defer inv2.Close()
+
+	local1 := NewInvalidated(NewSyncMap(errNotFound), inv1)
+	local2 := NewInvalidated(NewSyncMap(errNotFound), inv2)
+
+	assert.NoError(t, local2.SetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", "abc"))
+
+	// the write of replica 2 must not evict its own entry
+	time.Sleep(time.Millisecond * 100)
+	var val string
+	assert.NoError(t, local2.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", &val))
+
+	assert.NoError(t, local1.DelCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc"))
+	assert.Eventually(t, func() bool {
+		return local2.IsNotFound(local2.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", &val))
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestTwoLevelWithInvalidator(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	rds := redis.New(r.Addr())
+	errNotFound := errors.New("not found")
+
+	inv1, err := NewInvalidator(rds)
+	assert.NoError(t, err)
+	defer inv1.Close()
+	inv2, err := NewInvalidator(rds)
+	assert.NoError(t, err)
+	defer inv2.Close()
+
+	l2 := NewRedisNode(rds, errNotFound)
+	cache1 := NewTwoLevel(NewSyncMap(errNotFound), l2, WithInvalidator(inv1))
+	cache2 := NewTwoLevel(NewSyncMap(errNotFound), l2, WithInvalidator(inv2))
+
+	assert.NoError(t, cache1.SetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", "abc"))
+	var val string
+	assert.NoError(t, cache2.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", &val))
+	assert.Equal(t, "abc", val)
+
+	assert.NoError(t, cache1.SetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", "def"))
+	assert.Eventually(t, func() bool {
+		var val string
+		return cache2.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", &val) == nil && val == "def"
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestInvalidatorTLSConfig(t *testing.T) {
+	assert.Nil(t, opts.DefaultApply[InvalidatorOpts]().tlsConfig())
+
+	// certificates are verified by default
+	config := opts.DefaultApply(WithInvalidateTLS(true)).tlsConfig()
+	assert.NotNil(t, config)
+	assert.False(t, config.InsecureSkipVerify)
+
+	config = opts.DefaultApply(WithInvalidateInsecureSkipVerify(true)).tlsConfig()
+	assert.NotNil(t, config)
+	assert.True(t, config.InsecureSkipVerify)
+
+	custom := &tls.Config{ServerName: "redis.internal", MinVersion: tls.VersionTLS13}
+	config = opts.DefaultApply(WithInvalidateTLSConfig(custom)).tlsConfig()
+	assert.Equal(t, "redis.internal", config.ServerName)
+	assert.False(t, config.InsecureSkipVerify)
+	assert.NotSame(t, custom, config)
+}
+
+func TestInvalidatorTags(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	rds := redis.New(r.Addr())
+	errNotFound := errors.New("not found")
+
+	inv1, err := NewInvalidator(rds)
+	assert.NoError(t, err)
+	defer inv1.Close()
+	inv2, err := NewInvalidator(rds)
+	assert.NoError(t, err)
+	defer inv2.Close()
+
+	local1 := NewInvalidated(NewSyncMap(errNotFound), inv1)
+	local2 := NewInvalidated(NewSyncMap(errNotFound), inv2)
+
+	// only replica 2 knows the key of the tag
+	assert.NoError(t, local2.SetWithTagsCtx(context.Background(), "user:1", "jzero", time.Minute, "users"))
+
+	assert.NoError(t, local1.InvalidateTagsCtx(context.Background(), "users"))
+	var val string
+	assert.Eventually(t, func() bool {
+		return local2.IsNotFound(local2.GetCtx(context.Background(), "user:1", &val))
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestInvalidatorPrefix(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	rds := redis.New(r.Addr())
+	errNotFound := errors.New("not found")
+
+	inv1, err := NewInvalidator(rds)
+	assert.NoError(t, err)
+	defer inv1.Close()
+	inv2, err := NewInvalidator(rds)
+	assert.NoError(t, err)
+	defer inv2.Close()
+
+	local1 := NewInvalidated(NewSyncMap(errNotFound), inv1)
+	local2 := NewInvalidated(NewSyncMap(errNotFound), inv2)
+
+	// only replica 2 holds the keys of the prefix
+	assert.NoError(t, local2.SetCtx(context.Background(), "user:1", "jzero"))
+	assert.NoError(t, local2.SetCtx(context.Background(), "order:1", "jzero"))
+
+	n, err := local1.DelPrefixCtx(context.Background(), "user:", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	var val string
+	assert.Eventually(t, func() bool {
+		return local2.IsNotFound(local2.GetCtx(context.Background(), "user:1", &val))
+	}, time.Second, time.Millisecond*10)
+	assert.NoError(t, local2.GetCtx(context.Background(), "order:1", &val))
+}
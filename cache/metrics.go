@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics observes cache drivers, every method gets the key namespace instead of the key,
+// so the cardinality stays bounded.
+type Metrics interface {
+	// Hit is called when a key is found in cache, including not found placeholders.
+	Hit(namespace string)
+	// Miss is called when a key is not in cache.
+	Miss(namespace string)
+	// Load is called after the query of Take loads a value, err is nil or the query error.
+	Load(namespace string, latency time.Duration, err error)
+	// Evict is called when syncMap drops an entry to fit its limits.
+	Evict(namespace string)
+	// Size is called with the change of syncMap entries, other drivers never report size.
+	Size(namespace string, delta int)
+}
+
+// KeyNamespace returns the part of key before the first ':', which is the namespace of go-zero cache keys,
+// such as "cache:user:id:1" belongs to "cache".
+func KeyNamespace(key string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// loadErr is the error reported to Metrics.Load, errNotFound is a successful load of nothing.
+func loadErr(err, errNotFound error) error {
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	return err
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Hit(string) {}
+
+func (nopMetrics) Miss(string) {}
+
+func (nopMetrics) Load(string, time.Duration, error) {}
+
+func (nopMetrics) Evict(string) {}
+
+func (nopMetrics) Size(string, int) {}
+
+// PrometheusMetrics is a Metrics which is also a prometheus.Collector,
+// register it by prometheus.MustRegister after passing it to WithMetrics.
+type PrometheusMetrics struct {
+	hits         *prometheus.CounterVec
+	misses       *prometheus.CounterVec
+	loads        *prometheus.CounterVec
+	loadDuration *prometheus.HistogramVec
+	evictions    *prometheus.CounterVec
+	size         *prometheus.GaugeVec
+}
+
+// NewPrometheusMetrics creates PrometheusMetrics, name is set as the "cache" label,
+// so metrics of several caches can be registered together.
+func NewPrometheusMetrics(name string) *PrometheusMetrics {
+	constLabels := prometheus.Labels{"cache": name}
+	labels := []string{"namespace"}
+
+	return &PrometheusMetrics{
+		hits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "jzero",
+			Subsystem:   "cache",
+			Name:        "hits_total",
+			Help:        "jzero cache hits.",
+			ConstLabels: constLabels,
+		}, labels),
+		misses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "jzero",
+			Subsystem:   "cache",
+			Name:        "misses_total",
+			Help:        "jzero cache misses.",
+			ConstLabels: constLabels,
+		}, labels),
+		loads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "jzero",
+			Subsystem:   "cache",
+			Name:        "loads_total",
+			Help:        "jzero cache loads by the query of take.",
+			ConstLabels: constLabels,
+		}, append(labels, "result")),
+		loadDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   "jzero",
+			Subsystem:   "cache",
+			Name:        "load_duration_seconds",
+			Help:        "jzero cache load latency.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.DefBuckets,
+		}, labels),
+		evictions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "jzero",
+			Subsystem:   "cache",
+			Name:        "evictions_total",
+			Help:        "jzero cache evictions.",
+			ConstLabels: constLabels,
+		}, labels),
+		size: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "jzero",
+			Subsystem:   "cache",
+			Name:        "entries",
+			Help:        "jzero cache entries.",
+			ConstLabels: constLabels,
+		}, labels),
+	}
+}
+
+func (m *PrometheusMetrics) Hit(namespace string) {
+	m.hits.WithLabelValues(namespace).Inc()
+}
+
+func (m *PrometheusMetrics) Miss(namespace string) {
+	m.misses.WithLabelValues(namespace).Inc()
+}
+
+func (m *PrometheusMetrics) Load(namespace string, latency time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "fail"
+	}
+	m.loads.WithLabelValues(namespace, result).Inc()
+	m.loadDuration.WithLabelValues(namespace).Observe(latency.Seconds())
+}
+
+func (m *PrometheusMetrics) Evict(namespace string) {
+	m.evictions.WithLabelValues(namespace).Inc()
+}
+
+func (m *PrometheusMetrics) Size(namespace string, delta int) {
+	m.size.WithLabelValues(namespace).Add(float64(delta))
+}
+
+func (m *PrometheusMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.hits.Describe(ch)
+	m.misses.Describe(ch)
+	m.loads.Describe(ch)
+	m.loadDuration.Describe(ch)
+	m.evictions.Describe(ch)
+	m.size.Describe(ch)
+}
+
+func (m *PrometheusMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.hits.Collect(ch)
+	m.misses.Collect(ch)
+	m.loads.Collect(ch)
+	m.loadDuration.Collect(ch)
+	m.evictions.Collect(ch)
+	m.size.Collect(ch)
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+func TestKeyNamespace(t *testing.T) {
+	assert.Equal(t, "JWT_ADMIN_AUTH", KeyNamespace("JWT_ADMIN_AUTH:1:abc"))
+	assert.Equal(t, "abc", KeyNamespace("abc"))
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	errNotFound := errors.New("not found")
+	syncMapMetrics := NewPrometheusMetrics("sync-map")
+	redisMetrics := NewPrometheusMetrics("redis")
+
+	// metrics of several caches can be registered together
+	registry := prometheus.NewRegistry()
+	assert.NoError(t, registry.Register(syncMapMetrics))
+	assert.NoError(t, registry.Register(redisMetrics))
+
+	caches := map[*PrometheusMetrics]Cache{
+		syncMapMetrics: NewSyncMap(errNotFound, WithMetrics(syncMapMetrics), WithMaxEntries(1)),
+		redisMetrics:   NewRedisNodeWithOpts(redis.New(r.Addr()), errNotFound, WithMetrics(redisMetrics)),
+	}
+	for m, cache := range caches {
+		ctx := context.Background()
+
+		var val string
+		assert.NoError(t, cache.TakeCtx(ctx, &val, "JWT_ADMIN_AUTH:1:abc", func(v any) error {
+			*v.(*string) = "abc"
+			return nil
+		}))
+		assert.NoError(t, cache.GetCtx(ctx, "JWT_ADMIN_AUTH:1:abc", &val))
+		assert.True(t, cache.IsNotFound(cache.TakeCtx(ctx, &val, "JWT_ADMIN_AUTH:1:def", func(v any) error {
+			return errNotFound
+		})))
+
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.hits.WithLabelValues("JWT_ADMIN_AUTH")))
+		assert.Equal(t, float64(2), testutil.ToFloat64(m.misses.WithLabelValues("JWT_ADMIN_AUTH")))
+		assert.Equal(t, float64(2), testutil.ToFloat64(m.loads.WithLabelValues("JWT_ADMIN_AUTH", "ok")))
+		assert.Equal(t, 1, testutil.CollectAndCount(m.loadDuration))
+	}
+
+	// the placeholder of def evicted abc
+	assert.Equal(t, float64(1), testutil.ToFloat64(syncMapMetrics.evictions.WithLabelValues("JWT_ADMIN_AUTH")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(syncMapMetrics.size.WithLabelValues("JWT_ADMIN_AUTH")))
+	assert.Equal(t, 0, testutil.CollectAndCount(redisMetrics.evictions))
+}
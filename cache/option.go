@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"errors"
+	"time"
+
+	"github.com/eddieowens/opts"
+	zerocache "github.com/zeromicro/go-zero/core/stores/cache"
+)
+
+const (
+	defaultExpiry         = time.Hour * 24 * 7
+	defaultNotFoundExpiry = time.Minute
+	defaultCleanInterval  = time.Minute
+	defaultScanBatchSize  = 100
+
+	// same as go-zero cache node, so every driver behaves the same
+	notFoundPlaceholder = "*"
+	expiryDeviation     = 0.05
+)
+
+// errPlaceholder indicates there is no such value associate with the key
+var errPlaceholder = errors.New("placeholder")
+
+// DriverOpts are the options shared by NewSyncMap and NewRedisNode.
+type DriverOpts struct {
+	// Codec serializes cached values, default JsonCodec.
+	Codec Codec
+
+	// Expiry is the expiry used by SetCtx, TakeCtx and TakeWithExpireCtx unless ctx carries one by WithExpire,
+	// default 7 days, but syncMap keeps values without expiry unless it is set.
+	Expiry time.Duration
+
+	// NotFoundExpiry is the expiry of placeholders cached by Take when query returns errNotFound.
+	NotFoundExpiry time.Duration
+
+	// MaxEntries limits the number of syncMap entries, 0 means unlimited.
+	MaxEntries int
+
+	// MaxBytes limits the total size of syncMap keys and encoded values, 0 means unlimited.
+	MaxBytes int64
+
+	// Eviction chooses which syncMap entry is dropped when a limit is reached, default LRU.
+	Eviction EvictionPolicy
+
+	// CleanInterval is the interval of sweeping expired syncMap entries, 0 disables the janitor.
+	// The janitor starts with the first entry which expires and stops by Close.
+	CleanInterval time.Duration
+
+	// Metrics observes hits, misses, loads, evictions and size, default nothing is observed.
+	Metrics Metrics
+
+	// Namespace maps a key to the namespace reported to Metrics, default KeyNamespace.
+	Namespace func(key string) string
+}
+
+func (opts DriverOpts) DefaultOptions() DriverOpts {
+	return DriverOpts{
+		Codec:          JsonCodec,
+		Expiry:         defaultExpiry,
+		NotFoundExpiry: defaultNotFoundExpiry,
+		Eviction:       LRU,
+		CleanInterval:  defaultCleanInterval,
+		Metrics:        nopMetrics{},
+		Namespace:      KeyNamespace,
+	}
+}
+
+// syncMapExpiry returns the Expiry set by op, 0 if it is not set, syncMap keeps values without expiry by default.
+func syncMapExpiry(op ...opts.Opt[DriverOpts]) time.Duration {
+	var o DriverOpts
+	for _, apply := range op {
+		apply(&o)
+	}
+	return max(o.Expiry, 0)
+}
+
+func newDriverOpts(op ...opts.Opt[DriverOpts]) DriverOpts {
+	o := opts.DefaultApply(op...)
+	if o.Codec == nil {
+		o.Codec = JsonCodec
+	}
+	if o.Expiry <= 0 {
+		o.Expiry = defaultExpiry
+	}
+	if o.NotFoundExpiry <= 0 {
+		o.NotFoundExpiry = defaultNotFoundExpiry
+	}
+	if o.Metrics == nil {
+		o.Metrics = nopMetrics{}
+	}
+	if o.Namespace == nil {
+		o.Namespace = KeyNamespace
+	}
+	return o
+}
+
+func WithCodec(codec Codec) opts.Opt[DriverOpts] {
+	return func(o *DriverOpts) {
+		o.Codec = codec
+	}
+}
+
+func WithExpiry(expiry time.Duration) opts.Opt[DriverOpts] {
+	return func(o *DriverOpts) {
+		o.Expiry = expiry
+	}
+}
+
+func WithNotFoundExpiry(expiry time.Duration) opts.Opt[DriverOpts] {
+	return func(o *DriverOpts) {
+		o.NotFoundExpiry = expiry
+	}
+}
+
+// WithCacheOpts applies go-zero cache options, such as cache.WithExpiry and cache.WithNotFoundExpiry.
+func WithCacheOpts(cacheOpts ...zerocache.Option) opts.Opt[DriverOpts] {
+	return func(o *DriverOpts) {
+		var zo zerocache.Options
+		for _, cacheOpt := range cacheOpts {
+			cacheOpt(&zo)
+		}
+		if zo.Expiry > 0 {
+			o.Expiry = zo.Expiry
+		}
+		if zo.NotFoundExpiry > 0 {
+			o.NotFoundExpiry = zo.NotFoundExpiry
+		}
+	}
+}
+
+func WithMaxEntries(maxEntries int) opts.Opt[DriverOpts] {
+	return func(o *DriverOpts) {
+		o.MaxEntries = maxEntries
+	}
+}
+
+func WithMaxBytes(maxBytes int64) opts.Opt[DriverOpts] {
+	return func(o *DriverOpts) {
+		o.MaxBytes = maxBytes
+	}
+}
+
+func WithEviction(policy EvictionPolicy) opts.Opt[DriverOpts] {
+	return func(o *DriverOpts) {
+		o.Eviction = policy
+	}
+}
+
+func WithCleanInterval(interval time.Duration) opts.Opt[DriverOpts] {
+	return func(o *DriverOpts) {
+		o.CleanInterval = interval
+	}
+}
+
+func WithMetrics(metrics Metrics) opts.Opt[DriverOpts] {
+	return func(o *DriverOpts) {
+		o.Metrics = metrics
+	}
+}
+
+func WithNamespace(namespace func(key string) string) opts.Opt[DriverOpts] {
+	return func(o *DriverOpts) {
+		o.Namespace = namespace
+	}
+}
@@ -2,55 +2,370 @@ package cache
 
 import (
 	"context"
-	"math"
+	"errors"
 	"time"
 
-	"github.com/zeromicro/go-zero/core/jsonx"
+	"github.com/eddieowens/opts"
+	red "github.com/redis/go-redis/v9"
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/mathx"
 	zerocache "github.com/zeromicro/go-zero/core/stores/cache"
 	"github.com/zeromicro/go-zero/core/stores/redis"
 	"github.com/zeromicro/go-zero/core/syncx"
 )
 
+// redisNode delegates to go-zero cache node with JsonCodec, the other codecs store their raw bytes
+// the same way as go-zero cache node does, so values are stored in redis as the codec encodes them.
 type redisNode struct {
-	rds  *redis.Redis
-	node zerocache.Cache
+	rds            *redis.Redis
+	node           zerocache.Cache
+	barrier        syncx.SingleFlight
+	stat           *zerocache.Stat
+	refreshing     syncx.SingleFlight
+	errNotFound    error
+	expiry         time.Duration
+	notFoundExpiry time.Duration
+	unstableExpiry mathx.Unstable
+	codec          Codec
+	metrics        Metrics
+	namespace      func(key string) string
 }
 
 func (c redisNode) ExpireCtx(ctx context.Context, key string, expire time.Duration) error {
-	return c.rds.ExpireCtx(ctx, key, int(math.Ceil(expire.Seconds())))
+	// go-zero redis only expires in seconds
+	return c.rds.PipelinedCtx(ctx, func(p redis.Pipeliner) error {
+		p.PExpire(ctx, key, time.Duration(milliseconds(expire))*time.Millisecond)
+		return nil
+	})
+}
+
+func (c redisNode) ttlCtx(ctx context.Context, key string) (time.Duration, bool) {
+	var ttl *red.DurationCmd
+	if err := c.rds.PipelinedCtx(ctx, func(p redis.Pipeliner) error {
+		ttl = p.PTTL(ctx, key)
+		return nil
+	}); err != nil {
+		return 0, false
+	}
+
+	switch d := ttl.Val(); {
+	case d == -1:
+		// no expire
+		return 0, true
+	case d <= 0:
+		// -2 means missing
+		return 0, false
+	default:
+		return d, true
+	}
+}
+
+// IncrByCtx is INCRBY with JsonCodec, which encodes counters as decimal strings as redis does,
+// the other codecs count by compare and swap, so the counter is encoded by the codec like other values.
+func (c redisNode) IncrByCtx(ctx context.Context, key string, delta int64) (int64, error) {
+	if c.codec == JsonCodec {
+		return c.rds.IncrbyCtx(ctx, key, delta)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		var val int64
+		old, err := c.rds.GetCtx(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if old != "" {
+			if err = c.unmarshal(old, &val); err != nil {
+				return 0, ErrNotInteger
+			}
+		}
+		if val, err = add(val, delta); err != nil {
+			return 0, err
+		}
+		data, err := c.marshal(val)
+		if err != nil {
+			return 0, err
+		}
+
+		var swapped bool
+		if old == "" {
+			swapped, err = c.setnx(ctx, key, data, 0)
+		} else {
+			var resp any
+			resp, err = c.rds.ScriptRunCtx(ctx, casScript, []string{key}, old, data)
+			swapped = resp == int64(1)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if swapped {
+			return val, nil
+		}
+		// changed meanwhile, count again
+	}
+}
+
+func (c redisNode) DecrByCtx(ctx context.Context, key string, delta int64) (int64, error) {
+	if c.codec == JsonCodec {
+		return c.rds.DecrbyCtx(ctx, key, delta)
+	}
+
+	delta, err := negate(delta)
+	if err != nil {
+		return 0, err
+	}
+	return c.IncrByCtx(ctx, key, delta)
+}
+
+func (c redisNode) SetNXCtx(ctx context.Context, key string, val any, expire time.Duration) (bool, error) {
+	data, err := c.marshal(val)
+	if err != nil {
+		return false, err
+	}
+
+	return c.setnx(ctx, key, data, expire)
+}
+
+func (c redisNode) CompareAndSwapCtx(ctx context.Context, key string, oldVal, newVal any) (bool, error) {
+	oldData, err := c.marshal(oldVal)
+	if err != nil {
+		return false, err
+	}
+	newData, err := c.marshal(newVal)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.rds.ScriptRunCtx(ctx, casScript, []string{key}, oldData, newData)
+	if err != nil {
+		return false, err
+	}
+	return resp == int64(1), nil
 }
 
 func (c redisNode) SetNoExpireCtx(ctx context.Context, key string, val any) error {
-	data, err := jsonx.Marshal(val)
+	data, err := c.marshal(val)
 	if err != nil {
 		return err
 	}
-	return c.rds.SetCtx(ctx, key, string(data))
+	return c.rds.SetCtx(ctx, key, data)
 }
 
 func (c redisNode) GetPrefixKeysCtx(ctx context.Context, keyPrefix string) ([]string, error) {
-	var (
-		cursor  uint64
-		allKeys []string
-		err     error
-	)
+	var allKeys []string
+	err := c.ScanPrefixCtx(ctx, keyPrefix, defaultScanBatchSize, func(keys []string) bool {
+		allKeys = append(allKeys, keys...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allKeys, nil
+}
 
+func (c redisNode) ScanPrefixCtx(ctx context.Context, prefix string, batchSize int, fn func(keys []string) bool) error {
+	if batchSize <= 0 {
+		batchSize = defaultScanBatchSize
+	}
+
+	var cursor uint64
 	for {
-		var keys []string
-		keys, cursor, err = c.rds.ScanCtx(ctx, cursor, keyPrefix+"*", 100)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		keys, next, err := c.rds.ScanCtx(ctx, cursor, prefix+"*", int64(batchSize))
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 && !fn(keys) {
+			return nil
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+func (c redisNode) DelPrefixCtx(ctx context.Context, prefix string, batchSize int) (int, error) {
+	return delPrefix(ctx, c.ScanPrefixCtx, c.DelCtx, prefix, batchSize)
+}
+
+func (c redisNode) MGetCtx(ctx context.Context, keys []string, valsPtr any) ([]string, error) {
+	vals, err := newBatchValues(valsPtr, len(keys))
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	datas, err := c.mget(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var notFound []string
+	for i, data := range datas {
+		if len(data) == 0 {
+			c.metrics.Miss(c.namespace(keys[i]))
+			notFound = append(notFound, keys[i])
+			continue
+		}
+		c.metrics.Hit(c.namespace(keys[i]))
+		if data == notFoundPlaceholder {
+			notFound = append(notFound, keys[i])
+			continue
+		}
+		if err = c.unmarshal(data, vals.index(i)); err != nil {
+			// same as go-zero cache node, an invalid value is treated as not found
+			logx.WithContext(ctx).Errorf("unmarshal cache, key: %s, value: %s, error: %v", keys[i], data, err)
+			notFound = append(notFound, keys[i])
+		}
+	}
+	return notFound, nil
+}
+
+func (c redisNode) MSetWithExpireCtx(ctx context.Context, kvs map[string]any, expire time.Duration) error {
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	datas := make(map[string]string, len(kvs))
+	for key, val := range kvs {
+		data, err := c.marshal(val)
+		if err != nil {
+			return err
+		}
+		datas[key] = data
+	}
+
+	return c.rds.PipelinedCtx(ctx, func(p redis.Pipeliner) error {
+		for key, data := range datas {
+			p.Set(ctx, key, data, expire)
+		}
+		return nil
+	})
+}
+
+func (c redisNode) SetWithTagsCtx(ctx context.Context, key string, val any, expire time.Duration, tags ...string) error {
+	if err := c.SetWithExpireCtx(ctx, key, val, expire); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if _, err := c.rds.ScriptRunCtx(ctx, tagScript, []string{tagKey(tag)}, key, milliseconds(expire)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c redisNode) GetTagKeysCtx(ctx context.Context, tags ...string) ([]string, error) {
+	keys, err := c.tagMembersCtx(ctx, tags...)
+	if err != nil {
+		return nil, err
+	}
+	// tag sets keep the keys which are deleted or expired, until the tag is invalidated
+	return c.existing(ctx, keys)
+}
+
+// tagMembersCtx returns the keys ever tagged with any of tags until they are invalidated,
+// including the keys which are deleted or expired.
+func (c redisNode) tagMembersCtx(ctx context.Context, tags ...string) ([]string, error) {
+	var allKeys []string
+	for _, tag := range tags {
+		keys, err := c.rds.SmembersCtx(ctx, tagKey(tag))
 		if err != nil {
 			return nil, err
 		}
 		allKeys = append(allKeys, keys...)
-		if cursor == 0 {
-			break
+	}
+	return uniqueKeys(allKeys), nil
+}
+
+// existing filters out the keys which do not exist.
+func (c redisNode) existing(ctx context.Context, keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return keys, nil
+	}
+
+	cmds := make([]*redis.IntCmd, len(keys))
+	err := c.rds.PipelinedCtx(ctx, func(p redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = p.Exists(ctx, key)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return allKeys, nil
+
+	var exists []string
+	for i, cmd := range cmds {
+		if cmd.Val() > 0 {
+			exists = append(exists, keys[i])
+		}
+	}
+	return exists, nil
+}
+
+func (c redisNode) InvalidateTagsCtx(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		keys, err := c.rds.SmembersCtx(ctx, tagKey(tag))
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		if err = c.DelCtx(ctx, keys...); err != nil {
+			return err
+		}
+		// SREM instead of DEL, keys tagged meanwhile stay in the tag set
+		members := make([]any, len(keys))
+		for i, key := range keys {
+			members[i] = key
+		}
+		if _, err = c.rds.SremCtx(ctx, tagKey(tag), members...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c redisNode) mget(ctx context.Context, keys []string) ([]string, error) {
+	if c.rds.Type != redis.ClusterType {
+		return c.rds.MgetCtx(ctx, keys...)
+	}
+
+	// keys may belong to different slots in cluster mode, which MGET does not allow
+	cmds := make([]*redis.StringCmd, len(keys))
+	err := c.rds.PipelinedCtx(ctx, func(p redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = p.Get(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	datas := make([]string, len(keys))
+	for i, cmd := range cmds {
+		datas[i] = cmd.Val()
+	}
+	return datas, nil
 }
 
 func (c redisNode) Del(keys ...string) error {
-	return c.node.Del(keys...)
+	return c.DelCtx(context.Background(), keys...)
 }
 
 func (c redisNode) DelCtx(ctx context.Context, keys ...string) error {
@@ -58,11 +373,22 @@ func (c redisNode) DelCtx(ctx context.Context, keys ...string) error {
 }
 
 func (c redisNode) Get(key string, val any) error {
-	return c.node.Get(key, val)
+	return c.GetCtx(context.Background(), key, val)
 }
 
 func (c redisNode) GetCtx(ctx context.Context, key string, val any) error {
-	return c.node.GetCtx(ctx, key, val)
+	var err error
+	if c.codec == JsonCodec {
+		err = c.node.GetCtx(ctx, key, val)
+	} else if err = c.doGetCache(ctx, key, val); errors.Is(err, errPlaceholder) {
+		err = c.errNotFound
+	}
+	if err == nil {
+		c.metrics.Hit(c.namespace(key))
+	} else {
+		c.metrics.Miss(c.namespace(key))
+	}
+	return err
 }
 
 func (c redisNode) IsNotFound(err error) bool {
@@ -70,43 +396,232 @@ func (c redisNode) IsNotFound(err error) bool {
 }
 
 func (c redisNode) Set(key string, val any) error {
-	return c.node.SetCtx(context.Background(), key, val)
+	return c.SetCtx(context.Background(), key, val)
 }
 
 func (c redisNode) SetCtx(ctx context.Context, key string, val any) error {
-	return c.node.SetCtx(ctx, key, val)
+	return c.SetWithExpireCtx(ctx, key, val, c.aroundDuration(expireFrom(ctx, c.expiry)))
 }
 
 func (c redisNode) SetWithExpire(key string, val any, expire time.Duration) error {
-	return c.node.SetWithExpireCtx(context.Background(), key, val, expire)
+	return c.SetWithExpireCtx(context.Background(), key, val, expire)
 }
 
 func (c redisNode) SetWithExpireCtx(ctx context.Context, key string, val any, expire time.Duration) error {
-	return c.node.SetWithExpireCtx(ctx, key, val, expire)
+	data, err := c.marshal(val)
+	if err != nil {
+		return err
+	}
+	// go-zero cache node only expires in seconds
+	return c.rds.PipelinedCtx(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, key, data, expire)
+		return nil
+	})
 }
 
 func (c redisNode) Take(val any, key string, query func(val any) error) error {
-	return c.node.Take(val, key, query)
+	return c.TakeCtx(context.Background(), val, key, query)
 }
 
 func (c redisNode) TakeCtx(ctx context.Context, val any, key string, query func(val any) error) error {
-	return c.node.TakeCtx(ctx, val, key, query)
+	return c.take(ctx, val, key, func(time.Duration) error {
+		return query(val)
+	})
 }
 
 func (c redisNode) TakeWithExpire(val any, key string, query func(val any, expire time.Duration) error) error {
-	return c.node.TakeWithExpire(val, key, query)
+	return c.TakeWithExpireCtx(context.Background(), val, key, query)
 }
 
 func (c redisNode) TakeWithExpireCtx(ctx context.Context, val any, key string, query func(val any, expire time.Duration) error) error {
-	return c.node.TakeWithExpireCtx(ctx, val, key, query)
+	return c.take(ctx, val, key, func(expire time.Duration) error {
+		return query(val, expire)
+	})
+}
+
+func (c redisNode) TakeWithRefreshCtx(ctx context.Context, val any, key string, softTTL, hardTTL time.Duration, query func(val any) error) error {
+	return takeWithRefresh(ctx, c, c.refreshing, val, key, softTTL, hardTTL, query)
+}
+
+// take delegates to go-zero cache node with JsonCodec, which caches loaded values with its own expiry,
+// so the expire carried by ctx is applied after the value is loaded.
+func (c redisNode) take(ctx context.Context, val any, key string, query func(expire time.Duration) error) error {
+	var loaded bool
+	load := func(expire time.Duration) error {
+		loaded = true
+		start := time.Now()
+		err := query(expire)
+		c.metrics.Load(c.namespace(key), time.Since(start), loadErr(err, c.errNotFound))
+		return err
+	}
+
+	var err error
+	expire, ok := ctxExpire(ctx)
+	switch {
+	case c.codec != JsonCodec:
+		if !ok {
+			expire = c.expiry
+		}
+		expire = c.aroundDuration(expire)
+		err = c.doTake(ctx, val, key, expire, func() error {
+			return load(expire)
+		})
+	case ok:
+		expire = c.aroundDuration(expire)
+		err = c.node.TakeCtx(ctx, val, key, func(any) error {
+			return load(expire)
+		})
+		if loaded && err == nil {
+			if e := c.ExpireCtx(ctx, key, expire); e != nil {
+				logx.WithContext(ctx).Errorf("expire cache, key: %s, error: %v", key, e)
+			}
+		}
+	default:
+		err = c.node.TakeWithExpireCtx(ctx, val, key, func(_ any, expire time.Duration) error {
+			return load(expire)
+		})
+	}
+
+	switch {
+	case loaded:
+		c.metrics.Miss(c.namespace(key))
+	case err == nil || c.IsNotFound(err):
+		// cached value or placeholder, including the ones loaded by concurrent takes
+		c.metrics.Hit(c.namespace(key))
+	default:
+		c.metrics.Miss(c.namespace(key))
+	}
+	return err
+}
+
+func (c redisNode) aroundDuration(duration time.Duration) time.Duration {
+	return c.unstableExpiry.AroundDuration(duration)
+}
+
+// doGetCache follows go-zero cache node with the codec, it returns errPlaceholder for the not found placeholder.
+func (c redisNode) doGetCache(ctx context.Context, key string, v any) error {
+	c.stat.IncrementTotal()
+	data, err := c.rds.GetCtx(ctx, key)
+	if err != nil {
+		c.stat.IncrementMiss()
+		return err
+	}
+
+	if len(data) == 0 {
+		c.stat.IncrementMiss()
+		return c.errNotFound
+	}
+
+	c.stat.IncrementHit()
+	if data == notFoundPlaceholder {
+		return errPlaceholder
+	}
+
+	if err = c.unmarshal(data, v); err != nil {
+		// the raw bytes of a binary codec are not logged
+		logger := logx.WithContext(ctx)
+		logger.Errorf("unmarshal cache, node: %s, key: %s, error: %v", c.rds.Addr, key, err)
+		if _, e := c.rds.DelCtx(ctx, key); e != nil {
+			logger.Errorf("delete invalid cache, node: %s, key: %s, error: %v", c.rds.Addr, key, e)
+		}
+		// returns errNotFound to reload the value by the given query
+		return c.errNotFound
+	}
+	return nil
+}
+
+// doTake collapses concurrent loads of the same key and caches errNotFound as a placeholder,
+// it follows go-zero cache node with the codec, and caches the loaded value with expire.
+func (c redisNode) doTake(ctx context.Context, v any, key string, expire time.Duration, query func() error) error {
+	logger := logx.WithContext(ctx)
+	val, fresh, err := c.barrier.DoEx(key, func() (any, error) {
+		if err := c.doGetCache(ctx, key, v); err != nil {
+			if errors.Is(err, errPlaceholder) {
+				return nil, c.errNotFound
+			} else if !errors.Is(err, c.errNotFound) {
+				// fail fast instead of passing the load to the db
+				return nil, err
+			}
+
+			if err = query(); errors.Is(err, c.errNotFound) {
+				if _, err = c.setnx(ctx, key, notFoundPlaceholder, c.aroundDuration(c.notFoundExpiry)); err != nil {
+					logger.Error(err)
+				}
+				return nil, c.errNotFound
+			} else if err != nil {
+				c.stat.IncrementDbFails()
+				return nil, err
+			}
+
+			if err = c.SetWithExpireCtx(ctx, key, v, expire); err != nil {
+				logger.Error(err)
+			}
+		}
+
+		return c.codec.Marshal(v)
+	})
+	if err != nil {
+		return err
+	}
+	if fresh {
+		return nil
+	}
+
+	// got the result from previous ongoing query
+	c.stat.IncrementTotal()
+	c.stat.IncrementHit()
+	return c.codec.Unmarshal(val.([]byte), v)
+}
+
+func (c redisNode) marshal(val any) (string, error) {
+	data, err := c.codec.Marshal(val)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (c redisNode) unmarshal(data string, val any) error {
+	return c.codec.Unmarshal([]byte(data), val)
+}
+
+// setnx is SET NX with a millisecond precision expire, go-zero redis only expires in seconds.
+func (c redisNode) setnx(ctx context.Context, key, val string, expire time.Duration) (bool, error) {
+	var cmd *red.BoolCmd
+	if err := c.rds.PipelinedCtx(ctx, func(p redis.Pipeliner) error {
+		cmd = p.SetNX(ctx, key, val, expire)
+		return nil
+	}); err != nil {
+		return false, err
+	}
+	return cmd.Val(), nil
+}
+
+// NewRedisNode creates a cache on rds, which is go-zero cache node with the methods of Cache.
+// Use NewRedisNodeWithOpts for the options other than expiries, such as codec and metrics.
+func NewRedisNode(rds *redis.Redis, errNotFound error, cacheOpts ...zerocache.Option) Cache {
+	return NewRedisNodeWithOpts(rds, errNotFound, WithCacheOpts(cacheOpts...))
+}
+
+func NewRedisNodeWithOpts(rds *redis.Redis, errNotFound error, op ...opts.Opt[DriverOpts]) Cache {
+	return newRedisNode(rds, syncx.NewSingleFlight(), zerocache.NewStat("redis-cache"), errNotFound, newDriverOpts(op...))
 }
 
-func NewRedisNode(rds *redis.Redis, errNotFound error, opts ...zerocache.Option) Cache {
-	singleFlights := syncx.NewSingleFlight()
-	stats := zerocache.NewStat("redis-cache")
-	node := zerocache.NewNode(rds, singleFlights, stats, errNotFound, opts...)
+// newRedisNode creates a redisNode, barrier and stat may be shared by the nodes of a cluster.
+func newRedisNode(rds *redis.Redis, barrier syncx.SingleFlight, st *zerocache.Stat, errNotFound error, o DriverOpts) *redisNode {
 	return &redisNode{
-		rds:  rds,
-		node: node,
+		rds: rds,
+		node: zerocache.NewNode(rds, barrier, st, errNotFound,
+			zerocache.WithExpiry(o.Expiry), zerocache.WithNotFoundExpiry(o.NotFoundExpiry)),
+		barrier:        barrier,
+		stat:           st,
+		refreshing:     syncx.NewSingleFlight(),
+		errNotFound:    errNotFound,
+		expiry:         o.Expiry,
+		notFoundExpiry: o.NotFoundExpiry,
+		unstableExpiry: mathx.NewUnstable(expiryDeviation),
+		codec:          o.Codec,
+		metrics:        o.Metrics,
+		namespace:      o.Namespace,
 	}
 }
@@ -3,11 +3,14 @@ package cache
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	zerocache "github.com/zeromicro/go-zero/core/stores/cache"
 	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zeromicro/go-zero/core/syncx"
 )
 
 func TestRedisNode(t *testing.T) {
@@ -31,3 +34,128 @@ func TestRedisNode(t *testing.T) {
 
 	assert.Equal(t, []string{"JWT_ADMIN_AUTH:1:abc", "JWT_ADMIN_AUTH:1:def", "JWT_ADMIN_AUTH:1:ghi"}, keys)
 }
+
+func TestRedisNodeBatch(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	cache := NewRedisNode(redis.New(r.Addr()), errors.New("not found"))
+
+	err = cache.MSetWithExpireCtx(context.Background(), map[string]any{
+		"JWT_ADMIN_AUTH:1:abc": "abc",
+		"JWT_ADMIN_AUTH:1:def": "def",
+	}, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, r.TTL("JWT_ADMIN_AUTH:1:abc") > 0)
+
+	var vals []string
+	notFound, err := cache.MGetCtx(context.Background(), []string{"JWT_ADMIN_AUTH:1:abc", "JWT_ADMIN_AUTH:1:ghi", "JWT_ADMIN_AUTH:1:def"}, &vals)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"JWT_ADMIN_AUTH:1:ghi"}, notFound)
+	assert.Equal(t, []string{"abc", "", "def"}, vals)
+}
+
+func TestRedisNodeTags(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	cache := NewRedisNode(redis.New(r.Addr()), errors.New("not found"))
+
+	assert.NoError(t, cache.SetWithTagsCtx(context.Background(), "user:42:profile", "profile", time.Minute, "user:42"))
+	assert.NoError(t, cache.SetWithTagsCtx(context.Background(), "user:42:orders", "orders", time.Hour, "user:42", "orders"))
+	assert.NoError(t, cache.SetWithTagsCtx(context.Background(), "user:43:orders", "orders", time.Minute, "orders"))
+	assert.Equal(t, time.Hour, r.TTL(tagKey("user:42")))
+
+	keys, err := cache.GetTagKeysCtx(context.Background(), "user:42")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"user:42:profile", "user:42:orders"}, keys)
+
+	assert.NoError(t, cache.InvalidateTagsCtx(context.Background(), "user:42"))
+
+	var val string
+	assert.True(t, cache.IsNotFound(cache.GetCtx(context.Background(), "user:42:profile", &val)))
+	assert.True(t, cache.IsNotFound(cache.GetCtx(context.Background(), "user:42:orders", &val)))
+	assert.NoError(t, cache.GetCtx(context.Background(), "user:43:orders", &val))
+	assert.False(t, r.Exists(tagKey("user:42")))
+}
+
+func TestRedisNodeScanPrefix(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	cache := NewRedisNode(redis.New(r.Addr()), errors.New("not found"))
+	ctx := context.Background()
+
+	for _, key := range []string{"JWT_ADMIN_AUTH:1:abc", "JWT_ADMIN_AUTH:1:def", "JWT_ADMIN_AUTH:1:ghi", "JWT_ADMIN_AUTH:2:abc"} {
+		assert.NoError(t, cache.SetCtx(ctx, key, "v"))
+	}
+
+	var keys []string
+	err = cache.ScanPrefixCtx(ctx, "JWT_ADMIN_AUTH:1:", 1, func(batch []string) bool {
+		keys = append(keys, batch...)
+		return true
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"JWT_ADMIN_AUTH:1:abc", "JWT_ADMIN_AUTH:1:def", "JWT_ADMIN_AUTH:1:ghi"}, keys)
+
+	var batches int
+	err = cache.ScanPrefixCtx(ctx, "JWT_ADMIN_AUTH:1:", 1, func(batch []string) bool {
+		batches++
+		return false
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, batches)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = cache.ScanPrefixCtx(canceled, "JWT_ADMIN_AUTH:1:", 1, func(batch []string) bool {
+		return true
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// a single batch, miniredis pages SCAN by offset and skips the keys after a deleted batch
+	deleted, err := cache.DelPrefixCtx(ctx, "JWT_ADMIN_AUTH:1:", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+
+	keys, err = cache.GetPrefixKeysCtx(ctx, "JWT_ADMIN_AUTH:")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"JWT_ADMIN_AUTH:2:abc"}, keys)
+}
+
+func TestRedisNodeZeroCompatible(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	errNotFound := errors.New("not found")
+	rds := redis.New(r.Addr())
+	cache := NewRedisNode(rds, errNotFound, zerocache.WithExpiry(time.Minute))
+	zero := zerocache.NewNode(rds, syncx.NewSingleFlight(), zerocache.NewStat("test"), errNotFound)
+	ctx := context.Background()
+
+	var val string
+	assert.NoError(t, cache.TakeCtx(ctx, &val, "JWT_ADMIN_AUTH:1:abc", func(v any) error {
+		*v.(*string) = "abc"
+		return nil
+	}))
+	assert.LessOrEqual(t, r.TTL("JWT_ADMIN_AUTH:1:abc"), time.Minute+time.Second*3)
+
+	// values are shared with go-zero cache node
+	var got string
+	assert.NoError(t, zero.GetCtx(ctx, "JWT_ADMIN_AUTH:1:abc", &got))
+	assert.Equal(t, "abc", got)
+	assert.NoError(t, zero.SetCtx(ctx, "JWT_ADMIN_AUTH:1:def", "def"))
+	assert.NoError(t, cache.GetCtx(ctx, "JWT_ADMIN_AUTH:1:def", &got))
+	assert.Equal(t, "def", got)
+
+	// the expire of ctx applies to loaded values
+	assert.NoError(t, cache.TakeCtx(WithExpire(ctx, time.Second*10), &val, "JWT_ADMIN_AUTH:1:ghi", func(v any) error {
+		*v.(*string) = "ghi"
+		return nil
+	}))
+	assert.LessOrEqual(t, r.TTL("JWT_ADMIN_AUTH:1:ghi"), time.Second*11)
+}
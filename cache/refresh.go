@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/syncx"
+	"github.com/zeromicro/go-zero/core/threading"
+)
+
+var (
+	ErrInvalidRefreshTTL = errors.New("cache: softTTL must be positive and not greater than hardTTL")
+
+	// ErrInvalidRefreshVal is returned by TakeWithRefreshCtx when val is not a non-nil pointer.
+	ErrInvalidRefreshVal = errors.New("cache: val must be a non-nil pointer")
+)
+
+// newRefreshEntry returns a pointer to struct{ FreshUntil int64; Value T } for val of *T.
+// The freshness deadline is cached in the same entry as the value, so both are written at once.
+func newRefreshEntry(val any) (reflect.Value, error) {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return reflect.Value{}, ErrInvalidRefreshVal
+	}
+
+	typ := reflect.StructOf([]reflect.StructField{
+		{
+			Name: "FreshUntil",
+			Type: reflect.TypeOf(int64(0)),
+			Tag:  `json:"fresh_until" msgpack:"fresh_until"`,
+		},
+		{
+			Name: "Value",
+			Type: rv.Type().Elem(),
+			Tag:  `json:"value" msgpack:"value"`,
+		},
+	})
+	return reflect.New(typ), nil
+}
+
+// loadRefreshEntry fills entry by query, the value is fresh for softTTL.
+func loadRefreshEntry(entry reflect.Value, softTTL time.Duration, query func(val any) error) error {
+	if err := query(entry.Elem().Field(1).Addr().Interface()); err != nil {
+		return err
+	}
+	entry.Elem().Field(0).SetInt(time.Now().Add(softTTL).UnixNano())
+	return nil
+}
+
+// takeWithRefresh serves the value of key until hardTTL, a value older than softTTL is stale,
+// it is still returned at once while query reloads it in background, refreshing collapses the reloads of a key.
+// The value is cached with its freshness deadline, so the key must only be read by TakeWithRefreshCtx.
+func takeWithRefresh(ctx context.Context, c Cache, refreshing syncx.SingleFlight, val any, key string,
+	softTTL, hardTTL time.Duration, query func(val any) error,
+) error {
+	if softTTL <= 0 || hardTTL < softTTL {
+		return ErrInvalidRefreshTTL
+	}
+	entry, err := newRefreshEntry(val)
+	if err != nil {
+		return err
+	}
+
+	// missing or past hardTTL, callers block on loading
+	var loaded bool
+	if err = c.TakeCtx(WithExpire(ctx, hardTTL), entry.Interface(), key, func(v any) error {
+		loaded = true
+		return loadRefreshEntry(reflect.ValueOf(v), softTTL, query)
+	}); err != nil {
+		return err
+	}
+
+	reflect.ValueOf(val).Elem().Set(entry.Elem().Field(1))
+	if loaded || time.Now().UnixNano() < entry.Elem().Field(0).Int() {
+		return nil
+	}
+
+	// stale, serve it and reload in background
+	ctx = context.WithoutCancel(ctx)
+	threading.GoSafe(func() {
+		_, _ = refreshing.Do(key, func() (any, error) {
+			logger := logx.WithContext(ctx)
+			fresh := reflect.New(entry.Type().Elem())
+			if err := loadRefreshEntry(fresh, softTTL, query); err != nil {
+				if c.IsNotFound(err) {
+					// gone, the next take caches the placeholder
+					if err = c.DelCtx(ctx, key); err != nil {
+						logger.Error(err)
+					}
+					return nil, nil
+				}
+				// keep serving the stale value until hardTTL
+				logger.Errorf("refresh cache, key: %s, error: %v", key, err)
+				return nil, err
+			}
+
+			if err := c.SetWithExpireCtx(ctx, key, fresh.Interface(), hardTTL); err != nil {
+				logger.Error(err)
+			}
+			return nil, nil
+		})
+	})
+	return nil
+}
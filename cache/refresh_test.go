@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+func TestTakeWithRefreshFailure(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	errNotFound := errors.New("not found")
+	cache := NewRedisNode(redis.New(r.Addr()), errNotFound)
+	ctx := context.Background()
+
+	var val string
+	assert.NoError(t, cache.TakeWithRefreshCtx(ctx, &val, "JWT_ADMIN_AUTH:1:abc", time.Second, time.Minute, func(v any) error {
+		*v.(*string) = "abc"
+		return nil
+	}))
+
+	// a failed reload keeps serving the stale value
+	time.Sleep(time.Millisecond * 1100)
+	var calls int32
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, cache.TakeWithRefreshCtx(ctx, &val, "JWT_ADMIN_AUTH:1:abc", time.Second, time.Minute, func(v any) error {
+			atomic.AddInt32(&calls, 1)
+			return errors.New("db down")
+		}))
+		assert.Equal(t, "abc", val)
+	}
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) > 0
+	}, time.Second, time.Millisecond*10)
+
+	// a deleted row drops the stale value
+	assert.NoError(t, cache.TakeWithRefreshCtx(ctx, &val, "JWT_ADMIN_AUTH:1:abc", time.Second, time.Minute, func(v any) error {
+		return errNotFound
+	}))
+	assert.Equal(t, "abc", val)
+	assert.Eventually(t, func() bool {
+		return cache.IsNotFound(cache.GetCtx(ctx, "JWT_ADMIN_AUTH:1:abc", &val))
+	}, time.Second, time.Millisecond*10)
+}
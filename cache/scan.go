@@ -0,0 +1,29 @@
+package cache
+
+import "context"
+
+type (
+	scanFunc func(ctx context.Context, prefix string, batchSize int, fn func(keys []string) bool) error
+	delFunc  func(ctx context.Context, keys ...string) error
+)
+
+// delPrefix deletes keys matched prefix batch by batch in a single scan, returns the number of keys deleted.
+// Keys present during the whole scan are deleted, keys written while scanning may be missed,
+// and a key returned twice by the scan is counted twice.
+func delPrefix(ctx context.Context, scan scanFunc, del delFunc, prefix string, batchSize int) (int, error) {
+	var (
+		deleted int
+		delErr  error
+	)
+	err := scan(ctx, prefix, batchSize, func(keys []string) bool {
+		if delErr = del(ctx, keys...); delErr != nil {
+			return false
+		}
+		deleted += len(keys)
+		return true
+	})
+	if err != nil {
+		return deleted, err
+	}
+	return deleted, delErr
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type scanStore map[string]bool
+
+func (s scanStore) scan(_ context.Context, prefix string, batchSize int, fn func(keys []string) bool) error {
+	var keys []string
+	for key := range s {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for len(keys) > 0 {
+		n := min(batchSize, len(keys))
+		if !fn(keys[:n]) {
+			return nil
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+func (s scanStore) del(_ context.Context, keys ...string) error {
+	for _, key := range keys {
+		delete(s, key)
+	}
+	return nil
+}
+
+func TestDelPrefix(t *testing.T) {
+	ctx := context.Background()
+
+	store := scanStore{"a:1": true, "a:2": true, "a:3": true, "b:1": true}
+	deleted, err := delPrefix(ctx, store.scan, store.del, "a:", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+	assert.Equal(t, scanStore{"b:1": true}, store)
+
+	// the keys are scanned once
+	store = scanStore{"a:1": true, "a:2": true}
+	var passes int
+	deleted, err = delPrefix(ctx, func(ctx context.Context, prefix string, batchSize int, fn func(keys []string) bool) error {
+		passes++
+		return store.scan(ctx, prefix, batchSize, fn)
+	}, store.del, "a:", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, 1, passes)
+
+	store = scanStore{"a:1": true, "a:2": true}
+	errDel := errors.New("del failed")
+	_, err = delPrefix(ctx, store.scan, func(context.Context, ...string) error {
+		return errDel
+	}, "a:", 1)
+	assert.ErrorIs(t, err, errDel)
+}
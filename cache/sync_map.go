@@ -1,83 +1,206 @@
 package cache
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
-	"sync"
+	"runtime"
 	"time"
 
-	"github.com/zeromicro/go-zero/core/errorx"
+	"github.com/eddieowens/opts"
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/mathx"
+	"github.com/zeromicro/go-zero/core/syncx"
 )
 
 type (
 	syncMapItem struct {
-		data     []byte
+		data []byte
+		// duration is the expire unix nano of the item, 0 means no expire
 		duration int64
+		tags     []string
 	}
 
 	syncMap struct {
-		storage     *sync.Map
-		errNotFound error
+		storage        *syncMapStorage
+		errNotFound    error
+		barrier        syncx.SingleFlight
+		refreshing     syncx.SingleFlight
+		expiry         time.Duration
+		notFoundExpiry time.Duration
+		unstableExpiry mathx.Unstable
+		codec          Codec
+		metrics        Metrics
+		namespace      func(key string) string
 	}
 )
 
+// ExpireCtx resets the expire of key, zero expire deletes the key as redis does.
 func (sm *syncMap) ExpireCtx(ctx context.Context, key string, expire time.Duration) error {
-	item, err := sm.read(key)
-	if err != nil {
-		return err
+	if expire <= 0 {
+		sm.storage.Delete(key)
+		return nil
 	}
-	item.duration = time.Now().Unix() + int64(expire.Seconds())
-	sm.storage.Store(key, item)
-	return nil
+
+	return sm.storage.Update(key, time.Now().UnixNano(), func(item *syncMapItem) (*syncMapItem, error) {
+		if item == nil {
+			// same as redis, expiring a missing key is not an error
+			return nil, nil
+		}
+		return &syncMapItem{
+			data:     item.data,
+			duration: expireAt(expire),
+			tags:     item.tags,
+		}, nil
+	})
 }
 
 func (sm *syncMap) GetPrefixKeysCtx(ctx context.Context, prefix string) ([]string, error) {
-	var allKeys []string
+	return sm.storage.PrefixKeys(prefix, time.Now().UnixNano()), nil
+}
+
+func (sm *syncMap) ScanPrefixCtx(ctx context.Context, prefix string, batchSize int, fn func(keys []string) bool) error {
+	if batchSize <= 0 {
+		batchSize = defaultScanBatchSize
+	}
 
-	sm.storage.Range(func(key, value any) bool {
-		keyStr, ok := key.(string)
-		if !ok {
-			return true
+	keys := sm.storage.PrefixKeys(prefix, time.Now().UnixNano())
+	for start := 0; start < len(keys); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		if len(keyStr) >= len(prefix) && keyStr[:len(prefix)] == prefix {
-			allKeys = append(allKeys, keyStr)
+		end := start + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if !fn(keys[start:end]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (sm *syncMap) DelPrefixCtx(ctx context.Context, prefix string, batchSize int) (int, error) {
+	var deleted int
+	err := sm.ScanPrefixCtx(ctx, prefix, batchSize, func(keys []string) bool {
+		for _, key := range keys {
+			if sm.storage.Delete(key) {
+				deleted++
+			}
 		}
 		return true
 	})
+	return deleted, err
+}
+
+func (sm *syncMap) IncrByCtx(ctx context.Context, key string, delta int64) (int64, error) {
+	var val int64
+	err := sm.storage.Update(key, time.Now().UnixNano(), func(item *syncMapItem) (*syncMapItem, error) {
+		newItem := &syncMapItem{}
+		var data []byte
+		if item != nil {
+			data = item.data
+			newItem.duration = item.duration
+			newItem.tags = item.tags
+		}
+
+		var err error
+		newItem.data, val, err = incrBy(sm.codec, data, delta)
+		if err != nil {
+			return nil, err
+		}
+		return newItem, nil
+	})
+	return val, err
+}
+
+func (sm *syncMap) DecrByCtx(ctx context.Context, key string, delta int64) (int64, error) {
+	delta, err := negate(delta)
+	if err != nil {
+		return 0, err
+	}
+	return sm.IncrByCtx(ctx, key, delta)
+}
+
+func (sm *syncMap) SetNXCtx(ctx context.Context, key string, val any, expire time.Duration) (bool, error) {
+	data, err := sm.codec.Marshal(val)
+	if err != nil {
+		return false, err
+	}
 
-	return allKeys, nil
+	duration := expireAt(expire)
+	return sm.storage.StoreNX(key, &syncMapItem{data: data, duration: duration}, time.Now().UnixNano())
+}
+
+func (sm *syncMap) CompareAndSwapCtx(ctx context.Context, key string, oldVal, newVal any) (bool, error) {
+	oldData, err := sm.codec.Marshal(oldVal)
+	if err != nil {
+		return false, err
+	}
+	newData, err := sm.codec.Marshal(newVal)
+	if err != nil {
+		return false, err
+	}
+
+	var swapped bool
+	err = sm.storage.Update(key, time.Now().UnixNano(), func(item *syncMapItem) (*syncMapItem, error) {
+		if item == nil || !bytes.Equal(item.data, oldData) {
+			return nil, nil
+		}
+		swapped = true
+		return &syncMapItem{data: newData, duration: item.duration, tags: item.tags}, nil
+	})
+	return swapped, err
 }
 
 func (sm *syncMap) SetNoExpireCtx(ctx context.Context, key string, val any) error {
-	return sm.SetCtx(ctx, key, val)
+	return sm.SetWithExpireCtx(ctx, key, val, 0)
 }
 
-// NewSyncMap creates an instance of SyncMap cache driver
-func NewSyncMap(errNotFound error) Cache {
-	return &syncMap{
-		storage:     &sync.Map{},
-		errNotFound: errNotFound,
+// NewSyncMap creates an instance of SyncMap cache driver.
+// Expired entries are swept every CleanInterval once an entry may expire, the returned cache is an io.Closer
+// which stops sweeping, it is stopped when the cache is garbage collected as well.
+func NewSyncMap(errNotFound error, op ...opts.Opt[DriverOpts]) Cache {
+	o := newDriverOpts(op...)
+
+	sm := &syncMap{
+		storage:        newSyncMapStorage(o.MaxEntries, o.MaxBytes, o.Eviction, o.CleanInterval, o.Metrics, o.Namespace),
+		errNotFound:    errNotFound,
+		barrier:        syncx.NewSingleFlight(),
+		refreshing:     syncx.NewSingleFlight(),
+		expiry:         syncMapExpiry(op...),
+		notFoundExpiry: o.NotFoundExpiry,
+		unstableExpiry: mathx.NewUnstable(expiryDeviation),
+		codec:          o.Codec,
+		metrics:        o.Metrics,
+		namespace:      o.Namespace,
 	}
+
+	// the janitor only references the storage, so the finalizer is able to stop it if Close is never called
+	runtime.SetFinalizer(sm, func(sm *syncMap) {
+		sm.storage.Close()
+	})
+
+	return sm
+}
+
+// Close stops sweeping expired entries in background, the cache is still usable.
+func (sm *syncMap) Close() error {
+	sm.storage.Close()
+	return nil
 }
 
 func (sm *syncMap) Del(keys ...string) error {
 	return sm.DelCtx(context.Background(), keys...)
 }
 
+// DelCtx deletes keys, missing keys are ignored as redis does.
 func (sm *syncMap) DelCtx(ctx context.Context, keys ...string) error {
-	var be errorx.BatchError
-
 	for _, key := range keys {
-		if _, ok := sm.storage.Load(key); !ok {
-			be.Add(sm.errNotFound)
-		} else {
-			sm.storage.Delete(key)
-		}
+		sm.storage.Delete(key)
 	}
-
-	return be.Err()
+	return nil
 }
 
 func (sm *syncMap) Get(key string, val any) error {
@@ -85,12 +208,11 @@ func (sm *syncMap) Get(key string, val any) error {
 }
 
 func (sm *syncMap) GetCtx(ctx context.Context, key string, val any) error {
-	item, err := sm.read(key)
-	if err == nil {
-		return json.Unmarshal(item.data, val)
+	err := sm.doGetCache(key, val)
+	if errors.Is(err, errPlaceholder) {
+		return sm.errNotFound
 	}
-
-	return sm.errNotFound
+	return err
 }
 
 func (sm *syncMap) IsNotFound(err error) bool {
@@ -102,16 +224,7 @@ func (sm *syncMap) Set(key string, val any) error {
 }
 
 func (sm *syncMap) SetCtx(ctx context.Context, key string, val any) error {
-	data, err := json.Marshal(val)
-	if err != nil {
-		return err
-	}
-
-	sm.storage.Store(key, &syncMapItem{
-		data:     data,
-		duration: 0,
-	})
-	return nil
+	return sm.SetWithExpireCtx(ctx, key, val, sm.unstableExpiry.AroundDuration(expireFrom(ctx, sm.expiry)))
 }
 
 func (sm *syncMap) SetWithExpire(key string, val any, expire time.Duration) error {
@@ -119,16 +232,12 @@ func (sm *syncMap) SetWithExpire(key string, val any, expire time.Duration) erro
 }
 
 func (sm *syncMap) SetWithExpireCtx(ctx context.Context, key string, val any, expire time.Duration) error {
-	duration := int64(0)
-	if expire > 0 {
-		duration = time.Now().Unix() + int64(expire.Seconds())
-	}
-	data, err := json.Marshal(val)
+	duration := expireAt(expire)
+	data, err := sm.codec.Marshal(val)
 	if err != nil {
 		return err
 	}
-	sm.storage.Store(key, &syncMapItem{data: data, duration: duration})
-	return nil
+	return sm.storage.Store(key, &syncMapItem{data: data, duration: duration})
 }
 
 func (sm *syncMap) Take(val any, key string, query func(val any) error) error {
@@ -136,15 +245,9 @@ func (sm *syncMap) Take(val any, key string, query func(val any) error) error {
 }
 
 func (sm *syncMap) TakeCtx(ctx context.Context, val any, key string, query func(val any) error) error {
-	if _, ok := sm.storage.Load(key); ok {
-		return sm.GetCtx(ctx, key, val)
-	}
-
-	if err := query(val); err != nil {
-		return err
-	}
-
-	return sm.SetCtx(ctx, key, val)
+	return sm.doTake(ctx, val, key, query, func(v any) error {
+		return sm.SetCtx(ctx, key, v)
+	})
 }
 
 func (sm *syncMap) TakeWithExpire(val any, key string, query func(val any, expire time.Duration) error) error {
@@ -152,39 +255,167 @@ func (sm *syncMap) TakeWithExpire(val any, key string, query func(val any, expir
 }
 
 func (sm *syncMap) TakeWithExpireCtx(ctx context.Context, val any, key string, query func(val any, expire time.Duration) error) error {
-	if _, ok := sm.storage.Load(key); ok {
-		return sm.GetCtx(ctx, key, val)
+	expire := sm.unstableExpiry.AroundDuration(expireFrom(ctx, sm.expiry))
+
+	return sm.doTake(ctx, val, key, func(v any) error {
+		return query(v, expire)
+	}, func(v any) error {
+		return sm.SetWithExpireCtx(ctx, key, v, expire)
+	})
+}
+
+func (sm *syncMap) TakeWithRefreshCtx(ctx context.Context, val any, key string, softTTL, hardTTL time.Duration, query func(val any) error) error {
+	return takeWithRefresh(ctx, sm, sm.refreshing, val, key, softTTL, hardTTL, query)
+}
+
+func (sm *syncMap) doGetCache(key string, val any) error {
+	item, err := sm.read(key)
+	if err != nil {
+		sm.metrics.Miss(sm.namespace(key))
+		return sm.errNotFound
 	}
 
-	// patch
-	var expire time.Duration
-	if value, ok := ctx.Value("expire").(time.Duration); ok {
-		expire = value
+	sm.metrics.Hit(sm.namespace(key))
+	if string(item.data) == notFoundPlaceholder {
+		return errPlaceholder
 	}
 
-	if err := query(val, expire); err != nil {
+	return sm.codec.Unmarshal(item.data, val)
+}
+
+// doTake collapses concurrent loads of the same key and caches errNotFound as a placeholder,
+// it follows go-zero cache node.
+func (sm *syncMap) doTake(ctx context.Context, v any, key string, query func(v any) error, cacheVal func(v any) error) error {
+	val, fresh, err := sm.barrier.DoEx(key, func() (any, error) {
+		if err := sm.doGetCache(key, v); err != nil {
+			if errors.Is(err, errPlaceholder) {
+				return nil, sm.errNotFound
+			} else if !errors.Is(err, sm.errNotFound) {
+				return nil, err
+			}
+
+			start := time.Now()
+			err = query(v)
+			sm.metrics.Load(sm.namespace(key), time.Since(start), loadErr(err, sm.errNotFound))
+			if errors.Is(err, sm.errNotFound) {
+				sm.setCacheWithNotFound(key)
+				return nil, sm.errNotFound
+			} else if err != nil {
+				return nil, err
+			}
+
+			if err = cacheVal(v); err != nil {
+				logx.WithContext(ctx).Error(err)
+			}
+		}
+
+		return sm.codec.Marshal(v)
+	})
+	if err != nil {
 		return err
 	}
+	if fresh {
+		return nil
+	}
 
-	return sm.SetWithExpireCtx(ctx, key, val, expire)
+	// got the result from previous ongoing query
+	sm.metrics.Hit(sm.namespace(key))
+	return sm.codec.Unmarshal(val.([]byte), v)
+}
+
+func (sm *syncMap) setCacheWithNotFound(key string) {
+	expire := sm.unstableExpiry.AroundDuration(sm.notFoundExpiry)
+	_, _ = sm.storage.StoreNX(key, &syncMapItem{
+		data:     []byte(notFoundPlaceholder),
+		duration: expireAt(expire),
+	}, time.Now().UnixNano())
+}
+
+func (sm *syncMap) ttlCtx(ctx context.Context, key string) (time.Duration, bool) {
+	item, err := sm.read(key)
+	if err != nil {
+		return 0, false
+	}
+	return remaining(item.duration)
 }
 
 func (sm *syncMap) read(key string) (*syncMapItem, error) {
-	v, ok := sm.storage.Load(key)
+	item, ok := sm.storage.Load(key)
 	if !ok {
 		return nil, sm.errNotFound
 	}
 
-	item := v.(*syncMapItem)
-
 	if item.duration == 0 {
 		return item, nil
 	}
 
-	if item.duration <= time.Now().Unix() {
-		sm.storage.Delete(key)
-		return nil, errors.New("cache expired")
+	if item.duration <= time.Now().UnixNano() {
+		// the key may be set again meanwhile
+		sm.storage.DeleteIf(key, item)
+		return nil, sm.errNotFound
 	}
 
 	return item, nil
 }
+
+func (sm *syncMap) MGetCtx(ctx context.Context, keys []string, valsPtr any) ([]string, error) {
+	vals, err := newBatchValues(valsPtr, len(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	var notFound []string
+	for i, key := range keys {
+		if err = sm.doGetCache(key, vals.index(i)); err != nil {
+			if errors.Is(err, errPlaceholder) || errors.Is(err, sm.errNotFound) {
+				notFound = append(notFound, key)
+				continue
+			}
+			return nil, err
+		}
+	}
+	return notFound, nil
+}
+
+func (sm *syncMap) MSetWithExpireCtx(ctx context.Context, kvs map[string]any, expire time.Duration) error {
+	duration := expireAt(expire)
+
+	// marshal all values first, so a bad value does not leave the batch half written
+	items := make(map[string]*syncMapItem, len(kvs))
+	for key, val := range kvs {
+		data, err := sm.codec.Marshal(val)
+		if err != nil {
+			return err
+		}
+		item := &syncMapItem{data: data, duration: duration}
+		if err = sm.storage.fits(key, item); err != nil {
+			return err
+		}
+		items[key] = item
+	}
+
+	for key, item := range items {
+		if err := sm.storage.Store(key, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sm *syncMap) SetWithTagsCtx(ctx context.Context, key string, val any, expire time.Duration, tags ...string) error {
+	duration := expireAt(expire)
+	data, err := sm.codec.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return sm.storage.Store(key, &syncMapItem{data: data, duration: duration, tags: tags})
+}
+
+func (sm *syncMap) GetTagKeysCtx(ctx context.Context, tags ...string) ([]string, error) {
+	return sm.storage.TagKeys(time.Now().UnixNano(), tags...), nil
+}
+
+func (sm *syncMap) InvalidateTagsCtx(ctx context.Context, tags ...string) error {
+	sm.storage.DeleteTags(tags...)
+	return nil
+}
@@ -0,0 +1,322 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrEntryTooLarge is returned by syncMap when an entry is larger than MaxBytes, the old value of the key is kept.
+var ErrEntryTooLarge = errors.New("cache: entry is larger than MaxBytes")
+
+// syncMapStorage keeps syncMap items, bounded by entries and/or bytes when limits are set.
+// Reads share the lock unless the storage is bounded, since the evictor records every access.
+type syncMapStorage struct {
+	lock  sync.RWMutex
+	items map[string]*syncMapItem
+	bytes int64
+	// tags indexes keys by the tags of their items
+	tags map[string]map[string]struct{}
+
+	maxEntries int
+	maxBytes   int64
+	// evictor is nil when the storage is unbounded
+	evictor evictor
+
+	metrics   Metrics
+	namespace func(key string) string
+
+	// janitor is started by the first item which expires, it is nil before that
+	cleanInterval time.Duration
+	janitor       *janitor
+	closed        bool
+}
+
+func newSyncMapStorage(maxEntries int, maxBytes int64, policy EvictionPolicy, cleanInterval time.Duration,
+	metrics Metrics, namespace func(key string) string,
+) *syncMapStorage {
+	s := &syncMapStorage{
+		items:         make(map[string]*syncMapItem),
+		tags:          make(map[string]map[string]struct{}),
+		maxEntries:    maxEntries,
+		maxBytes:      maxBytes,
+		cleanInterval: cleanInterval,
+		metrics:       metrics,
+		namespace:     namespace,
+	}
+	if maxEntries > 0 || maxBytes > 0 {
+		s.evictor = newEvictor(policy)
+	}
+	return s
+}
+
+func (s *syncMapStorage) Load(key string) (*syncMapItem, bool) {
+	if s.evictor == nil {
+		s.lock.RLock()
+		defer s.lock.RUnlock()
+
+		item, ok := s.items[key]
+		return item, ok
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	item, ok := s.items[key]
+	if ok {
+		s.evictor.touch(key)
+	}
+	return item, ok
+}
+
+func (s *syncMapStorage) Store(key string, item *syncMapItem) error {
+	if err := s.fits(key, item); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.store(key, item)
+	return nil
+}
+
+// StoreNX stores item only if key is absent or expired before now.
+func (s *syncMapStorage) StoreNX(key string, item *syncMapItem, now int64) (bool, error) {
+	if err := s.fits(key, item); err != nil {
+		return false, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if existing, ok := s.items[key]; ok && (existing.duration == 0 || existing.duration > now) {
+		return false, nil
+	}
+
+	s.store(key, item)
+	return true, nil
+}
+
+// Update replaces the item of key by fn atomically, fn gets nil if key is absent or expired before now,
+// the item is kept as is if fn returns nil.
+func (s *syncMapStorage) Update(key string, now int64, fn func(item *syncMapItem) (*syncMapItem, error)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	item := s.items[key]
+	if item != nil && item.duration != 0 && item.duration <= now {
+		item = nil
+	}
+
+	newItem, err := fn(item)
+	if err != nil || newItem == nil {
+		return err
+	}
+	if err = s.fits(key, newItem); err != nil {
+		return err
+	}
+	s.store(key, newItem)
+	return nil
+}
+
+func (s *syncMapStorage) Delete(key string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.remove(key)
+}
+
+// DeleteIf deletes key only if it still holds item.
+func (s *syncMapStorage) DeleteIf(key string, item *syncMapItem) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.items[key] != item {
+		return false
+	}
+	return s.remove(key)
+}
+
+// TagKeys returns keys tagged with any of tags which are not expired before now.
+func (s *syncMapStorage) TagKeys(now int64, tags ...string) []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var keys []string
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			if item := s.items[key]; item.duration == 0 || item.duration > now {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return uniqueKeys(keys)
+}
+
+// DeleteTags deletes all keys tagged with any of tags.
+func (s *syncMapStorage) DeleteTags(tags ...string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			s.remove(key)
+		}
+	}
+}
+
+// PrefixKeys returns keys with prefix which are not expired before now.
+func (s *syncMapStorage) PrefixKeys(prefix string, now int64) []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var keys []string
+	for key, item := range s.items {
+		if strings.HasPrefix(key, prefix) && (item.duration == 0 || item.duration > now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// DeleteExpired sweeps all items expired before now.
+func (s *syncMapStorage) DeleteExpired(now int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for key, item := range s.items {
+		if item.duration != 0 && item.duration <= now {
+			s.remove(key)
+		}
+	}
+}
+
+// Close stops the janitor, expired items are still removed when they are read.
+func (s *syncMapStorage) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	if s.janitor != nil {
+		s.janitor.close()
+	}
+}
+
+// fits reports ErrEntryTooLarge for an item larger than the whole budget, such an item is never cached.
+func (s *syncMapStorage) fits(key string, item *syncMapItem) error {
+	if s.maxBytes > 0 && itemSize(key, item) > s.maxBytes {
+		return ErrEntryTooLarge
+	}
+	return nil
+}
+
+// store adds item which fits, replacing the item of key.
+func (s *syncMapStorage) store(key string, item *syncMapItem) {
+	s.remove(key)
+	size := itemSize(key, item)
+	if s.evictor != nil {
+		// evict before adding, otherwise lfu would drop the new item at once
+		for s.overflow(size) {
+			victim, ok := s.evictor.victim()
+			if !ok {
+				break
+			}
+			s.remove(victim)
+			s.metrics.Evict(s.namespace(victim))
+		}
+		s.evictor.add(key)
+	}
+
+	if item.duration != 0 {
+		s.startJanitor()
+	}
+	s.items[key] = item
+	s.bytes += size
+	s.metrics.Size(s.namespace(key), 1)
+	for _, tag := range item.tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = make(map[string]struct{})
+		}
+		s.tags[tag][key] = struct{}{}
+	}
+}
+
+func (s *syncMapStorage) remove(key string) bool {
+	item, ok := s.items[key]
+	if !ok {
+		return false
+	}
+	delete(s.items, key)
+	s.bytes -= itemSize(key, item)
+	s.metrics.Size(s.namespace(key), -1)
+	for _, tag := range item.tags {
+		delete(s.tags[tag], key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+	if s.evictor != nil {
+		s.evictor.remove(key)
+	}
+	return true
+}
+
+// overflow reports whether adding an item of size exceeds the limits.
+func (s *syncMapStorage) overflow(size int64) bool {
+	if s.maxEntries > 0 && len(s.items)+1 > s.maxEntries {
+		return true
+	}
+	return s.maxBytes > 0 && s.bytes+size > s.maxBytes
+}
+
+func itemSize(key string, item *syncMapItem) int64 {
+	return int64(len(key) + len(item.data))
+}
+
+// startJanitor starts sweeping expired items unless it is started, disabled or closed.
+func (s *syncMapStorage) startJanitor() {
+	if s.janitor != nil || s.cleanInterval <= 0 || s.closed {
+		return
+	}
+	s.janitor = newJanitor(s.cleanInterval)
+	go s.janitor.run(func() {
+		s.DeleteExpired(time.Now().UnixNano())
+	})
+}
+
+// janitor sweeps expired items periodically.
+type janitor struct {
+	interval time.Duration
+	stop     chan struct{}
+	once     sync.Once
+}
+
+func newJanitor(interval time.Duration) *janitor {
+	return &janitor{
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// close stops the janitor, it is safe to call more than once.
+func (j *janitor) close() {
+	j.once.Do(func() {
+		close(j.stop)
+	})
+}
+
+func (j *janitor) run(sweep func()) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sweep()
+		case <-j.stop:
+			return
+		}
+	}
+}
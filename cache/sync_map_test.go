@@ -2,6 +2,9 @@ package cache
 
 import (
 	"context"
+	"io"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -49,3 +52,269 @@ func TestSyncMapExpireCtx(t *testing.T) {
 	err = cache.Get("JWT_ADMIN_AUTH:1:abc", &newVal)
 	assert.Error(t, err)
 }
+
+func TestSyncMapExpireRace(t *testing.T) {
+	cache := NewSyncMap(errors.New("not found"))
+	sm := cache.(*syncMap)
+	ctx := context.Background()
+
+	// the expired item read before a concurrent set must not delete the new one
+	assert.NoError(t, sm.storage.Store("JWT_ADMIN_AUTH:1:abc", &syncMapItem{data: []byte(`"old"`), duration: 1}))
+	stale, ok := sm.storage.Load("JWT_ADMIN_AUTH:1:abc")
+	assert.True(t, ok)
+	assert.NoError(t, cache.SetCtx(ctx, "JWT_ADMIN_AUTH:1:abc", "new"))
+	assert.False(t, sm.storage.DeleteIf("JWT_ADMIN_AUTH:1:abc", stale))
+
+	var val string
+	assert.NoError(t, cache.GetCtx(ctx, "JWT_ADMIN_AUTH:1:abc", &val))
+	assert.Equal(t, "new", val)
+
+	// expiring keeps the value and does not bring back a missing key
+	assert.NoError(t, cache.ExpireCtx(ctx, "JWT_ADMIN_AUTH:1:abc", time.Minute))
+	assert.NoError(t, cache.GetCtx(ctx, "JWT_ADMIN_AUTH:1:abc", &val))
+	assert.Equal(t, "new", val)
+	assert.NoError(t, cache.ExpireCtx(ctx, "JWT_ADMIN_AUTH:1:def", time.Minute))
+	assert.True(t, cache.IsNotFound(cache.GetCtx(ctx, "JWT_ADMIN_AUTH:1:def", &val)))
+}
+
+func TestSyncMapDefaultNoExpiry(t *testing.T) {
+	ctx := context.Background()
+
+	// values are kept without expiry unless the expiry is set
+	cache := NewSyncMap(errors.New("not found")).(*syncMap)
+	assert.NoError(t, cache.SetCtx(ctx, "key", "val"))
+	item, err := cache.read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), item.duration)
+
+	// the untyped ctx key of earlier versions
+	var expire time.Duration
+	var val string
+	err = cache.TakeWithExpireCtx(context.WithValue(ctx, LegacyExpireKey, time.Minute), &val, "legacy", //nolint:staticcheck
+		func(val any, e time.Duration) error {
+			expire = e
+			*val.(*string) = "val"
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Minute, expire, float64(time.Minute)*expiryDeviation)
+
+	cache = NewSyncMap(errors.New("not found"), WithExpiry(time.Minute)).(*syncMap)
+	assert.NoError(t, cache.SetCtx(ctx, "key", "val"))
+	item, err = cache.read("key")
+	assert.NoError(t, err)
+	assert.NotZero(t, item.duration)
+}
+
+func TestSyncMapEviction(t *testing.T) {
+	t.Run("lru", func(t *testing.T) {
+		cache := NewSyncMap(errors.New("not found"), WithMaxEntries(2))
+
+		assert.NoError(t, cache.SetCtx(context.Background(), "a", "a"))
+		assert.NoError(t, cache.SetCtx(context.Background(), "b", "b"))
+
+		var val string
+		assert.NoError(t, cache.GetCtx(context.Background(), "a", &val))
+		assert.NoError(t, cache.SetCtx(context.Background(), "c", "c"))
+
+		assert.NoError(t, cache.GetCtx(context.Background(), "a", &val))
+		assert.True(t, cache.IsNotFound(cache.GetCtx(context.Background(), "b", &val)))
+		assert.NoError(t, cache.GetCtx(context.Background(), "c", &val))
+	})
+
+	t.Run("lfu", func(t *testing.T) {
+		cache := NewSyncMap(errors.New("not found"), WithMaxEntries(2), WithEviction(LFU))
+
+		assert.NoError(t, cache.SetCtx(context.Background(), "a", "a"))
+		assert.NoError(t, cache.SetCtx(context.Background(), "b", "b"))
+
+		var val string
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, cache.GetCtx(context.Background(), "a", &val))
+		}
+		assert.NoError(t, cache.GetCtx(context.Background(), "b", &val))
+		assert.NoError(t, cache.SetCtx(context.Background(), "c", "c"))
+
+		assert.NoError(t, cache.GetCtx(context.Background(), "a", &val))
+		assert.True(t, cache.IsNotFound(cache.GetCtx(context.Background(), "b", &val)))
+		assert.NoError(t, cache.GetCtx(context.Background(), "c", &val))
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		// every entry takes 1 byte of key and 5 bytes of json
+		cache := NewSyncMap(errors.New("not found"), WithMaxBytes(12))
+
+		assert.NoError(t, cache.SetCtx(context.Background(), "a", "aaa"))
+		assert.NoError(t, cache.SetCtx(context.Background(), "b", "bbb"))
+		assert.NoError(t, cache.SetCtx(context.Background(), "c", "ccc"))
+
+		keys, err := cache.GetPrefixKeysCtx(context.Background(), "")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"b", "c"}, keys)
+
+		// an entry larger than the budget fails and keeps the old value
+		assert.ErrorIs(t, cache.SetCtx(context.Background(), "c", "larger than 12 bytes"), ErrEntryTooLarge)
+		var val string
+		assert.NoError(t, cache.GetCtx(context.Background(), "c", &val))
+		assert.Equal(t, "ccc", val)
+	})
+}
+
+func TestSyncMapJanitor(t *testing.T) {
+	cache := NewSyncMap(errors.New("not found"), WithCleanInterval(time.Millisecond*100))
+	defer cache.(io.Closer).Close()
+
+	// nothing expires, so nothing is swept
+	storage := cache.(*syncMap).storage
+	assert.NoError(t, cache.SetCtx(context.Background(), "JWT_ADMIN_AUTH:1:def", "def"))
+	assert.Nil(t, storage.janitor)
+
+	err := cache.SetWithExpireCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", "abc", time.Second)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		keys, err := cache.GetPrefixKeysCtx(context.Background(), "JWT_ADMIN_AUTH:1:")
+		return err == nil && len(keys) == 1
+	}, time.Second*3, time.Millisecond*100)
+
+	assert.NoError(t, cache.(io.Closer).Close())
+	select {
+	case <-storage.janitor.stop:
+	default:
+		t.Fatal("janitor is not stopped")
+	}
+	assert.NoError(t, cache.(io.Closer).Close())
+}
+
+func TestSyncMapTakeCtx(t *testing.T) {
+	errNotFound := errors.New("not found")
+
+	t.Run("singleflight", func(t *testing.T) {
+		cache := NewSyncMap(errNotFound)
+
+		var calls int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var val string
+				err := cache.TakeCtx(context.Background(), &val, "JWT_ADMIN_AUTH:1:abc", func(val any) error {
+					atomic.AddInt32(&calls, 1)
+					time.Sleep(time.Millisecond * 100)
+					*val.(*string) = "abc"
+					return nil
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, "abc", val)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("not found placeholder", func(t *testing.T) {
+		cache := NewSyncMap(errNotFound)
+
+		var calls int
+		query := func(val any) error {
+			calls++
+			return errNotFound
+		}
+
+		var val string
+		for i := 0; i < 3; i++ {
+			err := cache.TakeCtx(context.Background(), &val, "JWT_ADMIN_AUTH:1:none", query)
+			assert.True(t, cache.IsNotFound(err))
+		}
+		assert.Equal(t, 1, calls)
+		assert.True(t, cache.IsNotFound(cache.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:none", &val)))
+
+		// a real value replaces the placeholder
+		assert.NoError(t, cache.SetCtx(context.Background(), "JWT_ADMIN_AUTH:1:none", "abc"))
+		assert.NoError(t, cache.TakeCtx(context.Background(), &val, "JWT_ADMIN_AUTH:1:none", query))
+		assert.Equal(t, "abc", val)
+	})
+}
+
+func TestSyncMapBatch(t *testing.T) {
+	cache := NewSyncMap(errors.New("not found"))
+
+	err := cache.MSetWithExpireCtx(context.Background(), map[string]any{
+		"JWT_ADMIN_AUTH:1:abc": "abc",
+		"JWT_ADMIN_AUTH:1:def": "def",
+	}, time.Minute)
+	assert.NoError(t, err)
+
+	var vals []string
+	notFound, err := cache.MGetCtx(context.Background(), []string{"JWT_ADMIN_AUTH:1:abc", "JWT_ADMIN_AUTH:1:ghi", "JWT_ADMIN_AUTH:1:def"}, &vals)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"JWT_ADMIN_AUTH:1:ghi"}, notFound)
+	assert.Equal(t, []string{"abc", "", "def"}, vals)
+
+	_, err = cache.MGetCtx(context.Background(), []string{"JWT_ADMIN_AUTH:1:abc"}, vals)
+	assert.ErrorIs(t, err, ErrInvalidBatchValues)
+}
+
+func TestSyncMapTags(t *testing.T) {
+	cache := NewSyncMap(errors.New("not found"))
+
+	assert.NoError(t, cache.SetWithTagsCtx(context.Background(), "user:42:profile", "profile", time.Minute, "user:42"))
+	assert.NoError(t, cache.SetWithTagsCtx(context.Background(), "user:42:orders", "orders", time.Minute, "user:42", "orders"))
+	assert.NoError(t, cache.SetWithTagsCtx(context.Background(), "user:43:orders", "orders", time.Minute, "orders"))
+
+	keys, err := cache.GetTagKeysCtx(context.Background(), "user:42")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"user:42:profile", "user:42:orders"}, keys)
+
+	assert.NoError(t, cache.InvalidateTagsCtx(context.Background(), "user:42"))
+
+	var val string
+	assert.True(t, cache.IsNotFound(cache.GetCtx(context.Background(), "user:42:profile", &val)))
+	assert.True(t, cache.IsNotFound(cache.GetCtx(context.Background(), "user:42:orders", &val)))
+	assert.NoError(t, cache.GetCtx(context.Background(), "user:43:orders", &val))
+
+	keys, err = cache.GetTagKeysCtx(context.Background(), "orders")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user:43:orders"}, keys)
+}
+
+func TestSyncMapScanPrefix(t *testing.T) {
+	cache := NewSyncMap(errors.New("not found"))
+	ctx := context.Background()
+
+	for _, key := range []string{"JWT_ADMIN_AUTH:1:abc", "JWT_ADMIN_AUTH:1:def", "JWT_ADMIN_AUTH:1:ghi", "JWT_ADMIN_AUTH:2:abc"} {
+		assert.NoError(t, cache.SetCtx(ctx, key, "v"))
+	}
+
+	var keys []string
+	err := cache.ScanPrefixCtx(ctx, "JWT_ADMIN_AUTH:1:", 1, func(batch []string) bool {
+		keys = append(keys, batch...)
+		return true
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"JWT_ADMIN_AUTH:1:abc", "JWT_ADMIN_AUTH:1:def", "JWT_ADMIN_AUTH:1:ghi"}, keys)
+
+	var batches int
+	err = cache.ScanPrefixCtx(ctx, "JWT_ADMIN_AUTH:1:", 1, func(batch []string) bool {
+		batches++
+		return false
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, batches)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = cache.ScanPrefixCtx(canceled, "JWT_ADMIN_AUTH:1:", 1, func(batch []string) bool {
+		return true
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	deleted, err := cache.DelPrefixCtx(ctx, "JWT_ADMIN_AUTH:1:", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+
+	keys, err = cache.GetPrefixKeysCtx(ctx, "JWT_ADMIN_AUTH:")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"JWT_ADMIN_AUTH:2:abc"}, keys)
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+const tagKeyPrefix = "jzero:cache:tag:"
+
+// tagScript adds ARGV[1] to the tag set KEYS[1], the tag set lives as long as its longest member.
+var tagScript = redis.NewScript(`local ttl = redis.call("PTTL", KEYS[1])
+redis.call("SADD", KEYS[1], ARGV[1])
+local expire = tonumber(ARGV[2])
+if expire <= 0 then
+    redis.call("PERSIST", KEYS[1])
+elseif ttl == -2 or (ttl >= 0 and ttl < expire) then
+    redis.call("PEXPIRE", KEYS[1], expire)
+end
+return 1`)
+
+// tagMembersCache is implemented by the drivers whose tag index keeps the keys which are deleted or expired.
+type tagMembersCache interface {
+	tagMembersCtx(ctx context.Context, tags ...string) ([]string, error)
+}
+
+// tagMembers returns the keys tagged with any of tags in c, including the keys which are deleted or expired
+// if c keeps them, the others drop such keys from their index.
+func tagMembers(ctx context.Context, c Cache, tags ...string) ([]string, error) {
+	if m, ok := c.(tagMembersCache); ok {
+		return m.tagMembersCtx(ctx, tags...)
+	}
+	return c.GetTagKeysCtx(ctx, tags...)
+}
+
+// tagKey is the redis set which holds the keys tagged with tag.
+func tagKey(tag string) string {
+	return tagKeyPrefix + tag
+}
+
+func uniqueKeys(keys []string) []string {
+	if len(keys) == 0 {
+		return keys
+	}
+
+	seen := make(map[string]struct{}, len(keys))
+	unique := keys[:0]
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, key)
+	}
+	return unique
+}
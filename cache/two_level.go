@@ -0,0 +1,400 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/eddieowens/opts"
+	"github.com/zeromicro/go-zero/core/errorx"
+)
+
+const defaultL1Expiry = time.Minute
+
+type (
+	TwoLevelOpts struct {
+		// L1Expiry is the expiry of entries kept in the local level.
+		L1Expiry time.Duration
+
+		// L2Expiry is the expiry used by SetCtx and SetNoExpireCtx on the remote level,
+		// zero means the default expiry of the l2 driver.
+		L2Expiry time.Duration
+
+		// Invalidator evicts l1 entries written by other instances.
+		Invalidator *Invalidator
+	}
+
+	// ttlCache is implemented by the drivers which tell the remaining ttl of a key,
+	// twoLevel caps the l1 expiry of a value read from l2 by it.
+	ttlCache interface {
+		// ttlCtx returns the remaining ttl of key, 0 means no expire, false if key is missing or the ttl is unknown.
+		ttlCtx(ctx context.Context, key string) (time.Duration, bool)
+	}
+
+	// twoLevel reads through l1 (usually syncMap) in front of l2 (usually redisNode),
+	// l2 is the source of truth and every write goes through both levels.
+	twoLevel struct {
+		l1 Cache
+		l2 Cache
+
+		options TwoLevelOpts
+	}
+)
+
+func (opts TwoLevelOpts) DefaultOptions() TwoLevelOpts {
+	return TwoLevelOpts{
+		L1Expiry: defaultL1Expiry,
+	}
+}
+
+func WithL1Expiry(expiry time.Duration) opts.Opt[TwoLevelOpts] {
+	return func(o *TwoLevelOpts) {
+		o.L1Expiry = expiry
+	}
+}
+
+func WithL2Expiry(expiry time.Duration) opts.Opt[TwoLevelOpts] {
+	return func(o *TwoLevelOpts) {
+		o.L2Expiry = expiry
+	}
+}
+
+func WithInvalidator(invalidator *Invalidator) opts.Opt[TwoLevelOpts] {
+	return func(o *TwoLevelOpts) {
+		o.Invalidator = invalidator
+	}
+}
+
+// NewTwoLevel creates a cache which reads l1 first and falls back to l2
+func NewTwoLevel(l1, l2 Cache, op ...opts.Opt[TwoLevelOpts]) Cache {
+	o := opts.DefaultApply(op...)
+	if o.L1Expiry <= 0 {
+		o.L1Expiry = defaultL1Expiry
+	}
+	if o.Invalidator != nil {
+		o.Invalidator.Register(l1)
+	}
+
+	return &twoLevel{
+		l1:      l1,
+		l2:      l2,
+		options: o,
+	}
+}
+
+func (tl *twoLevel) ExpireCtx(ctx context.Context, key string, expire time.Duration) error {
+	if err := tl.l2.ExpireCtx(ctx, key, expire); err != nil {
+		return err
+	}
+
+	if expire <= 0 {
+		if err := tl.l1.DelCtx(ctx, key); err != nil && !tl.l1.IsNotFound(err) {
+			return err
+		}
+	} else if err := tl.l1.ExpireCtx(ctx, key, tl.l1Expiry(expire)); err != nil && !tl.l1.IsNotFound(err) {
+		return err
+	}
+	return tl.publish(ctx, key)
+}
+
+// IncrByCtx counts on l2 only, l1 drops its copy so that reads see the new counter.
+func (tl *twoLevel) IncrByCtx(ctx context.Context, key string, delta int64) (int64, error) {
+	val, err := tl.l2.IncrByCtx(ctx, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	return val, tl.evict(ctx, key)
+}
+
+func (tl *twoLevel) DecrByCtx(ctx context.Context, key string, delta int64) (int64, error) {
+	val, err := tl.l2.DecrByCtx(ctx, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	return val, tl.evict(ctx, key)
+}
+
+// SetNXCtx checks existence on l2, l1 may miss keys which l2 holds.
+func (tl *twoLevel) SetNXCtx(ctx context.Context, key string, val any, expire time.Duration) (bool, error) {
+	set, err := tl.l2.SetNXCtx(ctx, key, val, expire)
+	if err != nil || !set {
+		return set, err
+	}
+	return true, tl.evict(ctx, key)
+}
+
+func (tl *twoLevel) CompareAndSwapCtx(ctx context.Context, key string, oldVal, newVal any) (bool, error) {
+	swapped, err := tl.l2.CompareAndSwapCtx(ctx, key, oldVal, newVal)
+	if err != nil || !swapped {
+		return swapped, err
+	}
+	return true, tl.evict(ctx, key)
+}
+
+func (tl *twoLevel) SetNoExpireCtx(ctx context.Context, key string, val any) error {
+	if err := tl.l2.SetNoExpireCtx(ctx, key, val); err != nil {
+		return err
+	}
+	if err := tl.l1.SetWithExpireCtx(ctx, key, val, tl.options.L1Expiry); err != nil {
+		return err
+	}
+	return tl.publish(ctx, key)
+}
+
+// GetPrefixKeysCtx only asks l2, l1 holds a subset of the keys at most.
+func (tl *twoLevel) GetPrefixKeysCtx(ctx context.Context, prefix string) ([]string, error) {
+	return tl.l2.GetPrefixKeysCtx(ctx, prefix)
+}
+
+func (tl *twoLevel) MGetCtx(ctx context.Context, keys []string, valsPtr any) ([]string, error) {
+	missed, err := tl.l1.MGetCtx(ctx, keys, valsPtr)
+	if err != nil || len(missed) == 0 {
+		return missed, err
+	}
+
+	vals := batchValuesOf(valsPtr)
+	l2ValsPtr := vals.newLike()
+	notFound, err := tl.l2.MGetCtx(ctx, missed, l2ValsPtr)
+	if err != nil {
+		return nil, err
+	}
+	l2Vals := batchValuesOf(l2ValsPtr)
+
+	missedIndex := make(map[string]int, len(missed))
+	for i, key := range missed {
+		missedIndex[key] = i
+	}
+	for _, key := range notFound {
+		delete(missedIndex, key)
+	}
+
+	for i, key := range keys {
+		if j, ok := missedIndex[key]; ok {
+			vals.set(i, l2Vals, j)
+			// every key is capped by its own remaining ttl in l2
+			tl.fillL1(ctx, key, vals.index(i), 0)
+		}
+	}
+
+	return notFound, nil
+}
+
+func (tl *twoLevel) MSetWithExpireCtx(ctx context.Context, kvs map[string]any, expire time.Duration) error {
+	if err := tl.l2.MSetWithExpireCtx(ctx, kvs, expire); err != nil {
+		return err
+	}
+	if err := tl.l1.MSetWithExpireCtx(ctx, kvs, tl.l1Expiry(expire)); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	return tl.publish(ctx, keys...)
+}
+
+func (tl *twoLevel) SetWithTagsCtx(ctx context.Context, key string, val any, expire time.Duration, tags ...string) error {
+	if err := tl.l2.SetWithTagsCtx(ctx, key, val, expire, tags...); err != nil {
+		return err
+	}
+	if err := tl.l1.SetWithTagsCtx(ctx, key, val, tl.l1Expiry(expire), tags...); err != nil {
+		return err
+	}
+	return tl.publish(ctx, key)
+}
+
+func (tl *twoLevel) GetTagKeysCtx(ctx context.Context, tags ...string) ([]string, error) {
+	return tl.l2.GetTagKeysCtx(ctx, tags...)
+}
+
+// InvalidateTagsCtx evicts l1 by its own tag index, which holds the entries written with tags, and by the tag index
+// of l2, since entries filled by reads are not tagged in l1. The keys of l2 include the keys deleted or expired in l2,
+// their copies may still be in l1.
+func (tl *twoLevel) InvalidateTagsCtx(ctx context.Context, tags ...string) error {
+	keys, err := tagMembers(ctx, tl.l2, tags...)
+	if err != nil {
+		return err
+	}
+	if err = tl.l2.InvalidateTagsCtx(ctx, tags...); err != nil {
+		return err
+	}
+	if err = tl.l1.InvalidateTagsCtx(ctx, tags...); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err = tl.l1.DelCtx(ctx, key); err != nil && !tl.l1.IsNotFound(err) {
+			return err
+		}
+	}
+	if err = tl.publish(ctx, keys...); err != nil {
+		return err
+	}
+	if tl.options.Invalidator == nil {
+		return nil
+	}
+	return tl.options.Invalidator.PublishTagsCtx(ctx, tags...)
+}
+
+func (tl *twoLevel) ScanPrefixCtx(ctx context.Context, prefix string, batchSize int, fn func(keys []string) bool) error {
+	return tl.l2.ScanPrefixCtx(ctx, prefix, batchSize, fn)
+}
+
+func (tl *twoLevel) DelPrefixCtx(ctx context.Context, prefix string, batchSize int) (int, error) {
+	return delPrefix(ctx, tl.l2.ScanPrefixCtx, tl.DelCtx, prefix, batchSize)
+}
+
+func (tl *twoLevel) Del(keys ...string) error {
+	return tl.DelCtx(context.Background(), keys...)
+}
+
+func (tl *twoLevel) DelCtx(ctx context.Context, keys ...string) error {
+	if err := tl.l2.DelCtx(ctx, keys...); err != nil {
+		return err
+	}
+
+	var be errorx.BatchError
+	for _, key := range keys {
+		if err := tl.l1.DelCtx(ctx, key); err != nil && !tl.l1.IsNotFound(err) {
+			be.Add(err)
+		}
+	}
+	be.Add(tl.publish(ctx, keys...))
+	return be.Err()
+}
+
+func (tl *twoLevel) Get(key string, val any) error {
+	return tl.GetCtx(context.Background(), key, val)
+}
+
+func (tl *twoLevel) GetCtx(ctx context.Context, key string, val any) error {
+	if err := tl.l1.GetCtx(ctx, key, val); err == nil {
+		return nil
+	}
+
+	if err := tl.l2.GetCtx(ctx, key, val); err != nil {
+		return err
+	}
+
+	tl.fillL1(ctx, key, val, 0)
+	return nil
+}
+
+func (tl *twoLevel) IsNotFound(err error) bool {
+	return tl.l2.IsNotFound(err) || tl.l1.IsNotFound(err)
+}
+
+func (tl *twoLevel) Set(key string, val any) error {
+	return tl.SetCtx(context.Background(), key, val)
+}
+
+func (tl *twoLevel) SetCtx(ctx context.Context, key string, val any) error {
+	var err error
+	expire := expireFrom(ctx, tl.options.L2Expiry)
+	if expire > 0 {
+		err = tl.l2.SetWithExpireCtx(ctx, key, val, expire)
+	} else {
+		err = tl.l2.SetCtx(ctx, key, val)
+	}
+	if err != nil {
+		return err
+	}
+	if err = tl.l1.SetWithExpireCtx(ctx, key, val, tl.l1Expiry(expire)); err != nil {
+		return err
+	}
+	return tl.publish(ctx, key)
+}
+
+func (tl *twoLevel) SetWithExpire(key string, val any, expire time.Duration) error {
+	return tl.SetWithExpireCtx(context.Background(), key, val, expire)
+}
+
+func (tl *twoLevel) SetWithExpireCtx(ctx context.Context, key string, val any, expire time.Duration) error {
+	if err := tl.l2.SetWithExpireCtx(ctx, key, val, expire); err != nil {
+		return err
+	}
+	if err := tl.l1.SetWithExpireCtx(ctx, key, val, tl.l1Expiry(expire)); err != nil {
+		return err
+	}
+	return tl.publish(ctx, key)
+}
+
+func (tl *twoLevel) Take(val any, key string, query func(val any) error) error {
+	return tl.TakeCtx(context.Background(), val, key, query)
+}
+
+// TakeCtx leaves singleflight and not found placeholders to l2, so the semantics of l2 are kept.
+func (tl *twoLevel) TakeCtx(ctx context.Context, val any, key string, query func(val any) error) error {
+	if err := tl.l1.GetCtx(ctx, key, val); err == nil {
+		return nil
+	}
+
+	if err := tl.l2.TakeCtx(ctx, val, key, query); err != nil {
+		return err
+	}
+
+	tl.fillL1(ctx, key, val, expireFrom(ctx, 0))
+	return nil
+}
+
+// TakeWithRefreshCtx bypasses l1, which is not able to tell stale values and would keep serving them.
+func (tl *twoLevel) TakeWithRefreshCtx(ctx context.Context, val any, key string, softTTL, hardTTL time.Duration, query func(val any) error) error {
+	return tl.l2.TakeWithRefreshCtx(ctx, val, key, softTTL, hardTTL, query)
+}
+
+func (tl *twoLevel) TakeWithExpire(val any, key string, query func(val any, expire time.Duration) error) error {
+	return tl.TakeWithExpireCtx(context.Background(), val, key, query)
+}
+
+func (tl *twoLevel) TakeWithExpireCtx(ctx context.Context, val any, key string, query func(val any, expire time.Duration) error) error {
+	if err := tl.l1.GetCtx(ctx, key, val); err == nil {
+		return nil
+	}
+
+	var queryExpire time.Duration
+	if err := tl.l2.TakeWithExpireCtx(ctx, val, key, func(val any, expire time.Duration) error {
+		queryExpire = expire
+		return query(val, expire)
+	}); err != nil {
+		return err
+	}
+
+	tl.fillL1(ctx, key, val, queryExpire)
+	return nil
+}
+
+// publish tells other instances to evict keys from their l1.
+func (tl *twoLevel) publish(ctx context.Context, keys ...string) error {
+	if tl.options.Invalidator == nil {
+		return nil
+	}
+	return tl.options.Invalidator.PublishCtx(ctx, keys...)
+}
+
+// evict drops key from l1 and the l1 of the other replicas after l2 changed it in place.
+func (tl *twoLevel) evict(ctx context.Context, key string) error {
+	if err := tl.l1.DelCtx(ctx, key); err != nil && !tl.l1.IsNotFound(err) {
+		return err
+	}
+	return tl.publish(ctx, key)
+}
+
+// fillL1 backfills l1 after a l2 read, failures only cost a later l2 round trip.
+// The l1 expiry is capped by the remaining ttl of key in l2, or by expire if l2 is not able to tell it.
+func (tl *twoLevel) fillL1(ctx context.Context, key string, val any, expire time.Duration) {
+	if c, ok := tl.l2.(ttlCache); ok {
+		if expire, ok = c.ttlCtx(ctx, key); !ok {
+			// gone from l2 already, or l2 is not reachable
+			return
+		}
+	}
+	_ = tl.l1.SetWithExpireCtx(ctx, key, val, tl.l1Expiry(expire))
+}
+
+// l1Expiry makes sure l1 never outlives the given l2 expiry.
+func (tl *twoLevel) l1Expiry(expire time.Duration) time.Duration {
+	if expire > 0 && expire < tl.options.L1Expiry {
+		return expire
+	}
+	return tl.options.L1Expiry
+}
@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+func TestTwoLevel(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	errNotFound := errors.New("not found")
+	l1 := NewSyncMap(errNotFound)
+	l2 := NewRedisNode(redis.New(r.Addr()), errNotFound)
+	cache := NewTwoLevel(l1, l2, WithL1Expiry(time.Second*10))
+
+	t.Run("write through", func(t *testing.T) {
+		err = cache.SetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", "abc")
+		assert.NoError(t, err)
+
+		var val string
+		assert.NoError(t, l1.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", &val))
+		assert.Equal(t, "abc", val)
+		assert.NoError(t, l2.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", &val))
+		assert.Equal(t, "abc", val)
+
+		err = cache.DelCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc")
+		assert.NoError(t, err)
+		assert.True(t, cache.IsNotFound(l1.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", &val)))
+		assert.True(t, cache.IsNotFound(l2.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", &val)))
+	})
+
+	t.Run("read through", func(t *testing.T) {
+		err = l2.SetCtx(context.Background(), "JWT_ADMIN_AUTH:1:def", "def")
+		assert.NoError(t, err)
+
+		var val string
+		assert.NoError(t, cache.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:def", &val))
+		assert.Equal(t, "def", val)
+
+		// served by l1 after l2 is gone
+		r.Del("JWT_ADMIN_AUTH:1:def")
+		val = ""
+		assert.NoError(t, cache.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:def", &val))
+		assert.Equal(t, "def", val)
+	})
+
+	t.Run("read through capped by l2 ttl", func(t *testing.T) {
+		err = l2.SetWithExpireCtx(context.Background(), "TTL:1:ttl", "ttl", time.Second)
+		assert.NoError(t, err)
+
+		var val string
+		assert.NoError(t, cache.GetCtx(context.Background(), "TTL:1:ttl", &val))
+		assert.Equal(t, "ttl", val)
+
+		ttl, ok := l1.(ttlCache).ttlCtx(context.Background(), "TTL:1:ttl")
+		assert.True(t, ok)
+		assert.LessOrEqual(t, ttl, time.Second)
+
+		// l1 is not filled with a key which l2 does not hold
+		cache.(*twoLevel).fillL1(context.Background(), "TTL:1:gone", "gone", 0)
+		assert.True(t, cache.IsNotFound(l1.GetCtx(context.Background(), "TTL:1:gone", &val)))
+	})
+
+	t.Run("take", func(t *testing.T) {
+		var calls int
+		query := func(val any) error {
+			calls++
+			*val.(*string) = "ghi"
+			return nil
+		}
+
+		for i := 0; i < 3; i++ {
+			var val string
+			err = cache.TakeCtx(context.Background(), &val, "JWT_ADMIN_AUTH:1:ghi", query)
+			assert.NoError(t, err)
+			assert.Equal(t, "ghi", val)
+		}
+		assert.Equal(t, 1, calls)
+
+		var val string
+		err = cache.TakeCtx(context.Background(), &val, "JWT_ADMIN_AUTH:2:none", func(val any) error {
+			return errNotFound
+		})
+		assert.True(t, cache.IsNotFound(err))
+	})
+
+	t.Run("prefix keys", func(t *testing.T) {
+		keys, err := cache.GetPrefixKeysCtx(context.Background(), "JWT_ADMIN_AUTH:1:")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"JWT_ADMIN_AUTH:1:ghi"}, keys)
+	})
+}
+
+func TestTwoLevelBatch(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	errNotFound := errors.New("not found")
+	l1 := NewSyncMap(errNotFound)
+	l2 := NewRedisNode(redis.New(r.Addr()), errNotFound)
+	cache := NewTwoLevel(l1, l2)
+
+	assert.NoError(t, l1.SetCtx(context.Background(), "JWT_ADMIN_AUTH:1:abc", "abc"))
+	assert.NoError(t, l2.SetCtx(context.Background(), "JWT_ADMIN_AUTH:1:def", "def"))
+
+	var vals []string
+	notFound, err := cache.MGetCtx(context.Background(), []string{"JWT_ADMIN_AUTH:1:abc", "JWT_ADMIN_AUTH:1:ghi", "JWT_ADMIN_AUTH:1:def"}, &vals)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"JWT_ADMIN_AUTH:1:ghi"}, notFound)
+	assert.Equal(t, []string{"abc", "", "def"}, vals)
+
+	// backfilled into l1
+	var val string
+	assert.NoError(t, l1.GetCtx(context.Background(), "JWT_ADMIN_AUTH:1:def", &val))
+	assert.Equal(t, "def", val)
+}
+
+func TestTwoLevelTags(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	errNotFound := errors.New("not found")
+	l1 := NewSyncMap(errNotFound)
+	l2 := NewRedisNode(redis.New(r.Addr()), errNotFound)
+	cache := NewTwoLevel(l1, l2)
+
+	assert.NoError(t, l2.SetWithTagsCtx(context.Background(), "user:42:profile", "profile", time.Minute, "user:42"))
+
+	// filled into l1 without tags
+	var val string
+	assert.NoError(t, cache.GetCtx(context.Background(), "user:42:profile", &val))
+
+	assert.NoError(t, cache.InvalidateTagsCtx(context.Background(), "user:42"))
+	assert.True(t, cache.IsNotFound(l1.GetCtx(context.Background(), "user:42:profile", &val)))
+	assert.True(t, cache.IsNotFound(cache.GetCtx(context.Background(), "user:42:profile", &val)))
+
+	// the l1 copy of a key which is already deleted in l2 is evicted as well
+	assert.NoError(t, l2.SetWithTagsCtx(context.Background(), "user:43:profile", "profile", time.Minute, "user:43"))
+	assert.NoError(t, cache.GetCtx(context.Background(), "user:43:profile", &val))
+	r.Del("user:43:profile")
+	keys, err := cache.GetTagKeysCtx(context.Background(), "user:43")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	assert.NoError(t, cache.InvalidateTagsCtx(context.Background(), "user:43"))
+	assert.True(t, cache.IsNotFound(l1.GetCtx(context.Background(), "user:43:profile", &val)))
+}
+
+func TestTwoLevelL2Expiry(t *testing.T) {
+	r, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	errNotFound := errors.New("not found")
+	l1 := NewSyncMap(errNotFound)
+	l2 := NewRedisNode(redis.New(r.Addr()), errNotFound)
+	cache := NewTwoLevel(l1, l2, WithL1Expiry(time.Second*10))
+
+	ctx := context.Background()
+	assert.NoError(t, l2.SetWithExpireCtx(ctx, "TTL:1:mget", "mget", time.Millisecond*500))
+	assert.NoError(t, l2.SetWithExpireCtx(ctx, "TTL:1:get", "get", time.Millisecond*500))
+
+	// fill l1 by both read paths
+	var vals []string
+	notFound, err := cache.MGetCtx(ctx, []string{"TTL:1:mget"}, &vals)
+	assert.NoError(t, err)
+	assert.Empty(t, notFound)
+	assert.Equal(t, []string{"mget"}, vals)
+	var val string
+	assert.NoError(t, cache.GetCtx(ctx, "TTL:1:get", &val))
+	assert.Equal(t, "get", val)
+
+	// l1 follows the real clock
+	r.FastForward(time.Millisecond * 500)
+	time.Sleep(time.Millisecond * 600)
+
+	vals = nil
+	notFound, err = cache.MGetCtx(ctx, []string{"TTL:1:mget"}, &vals)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"TTL:1:mget"}, notFound)
+	assert.True(t, cache.IsNotFound(cache.GetCtx(ctx, "TTL:1:get", &val)))
+}
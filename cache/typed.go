@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Typed wraps a Cache with values of type T, so callers neither declare a var nor pass pointers.
+type Typed[T any] struct {
+	cache Cache
+}
+
+func NewTyped[T any](cache Cache) Typed[T] {
+	return Typed[T]{cache: cache}
+}
+
+// Cache returns the underlying cache.
+func (t Typed[T]) Cache() Cache {
+	return t.cache
+}
+
+func (t Typed[T]) Get(ctx context.Context, key string) (T, error) {
+	var val T
+	err := t.cache.GetCtx(ctx, key, &val)
+	return val, err
+}
+
+// MGet returns values in the order of keys, missing keys are zero value and returned as notFound.
+func (t Typed[T]) MGet(ctx context.Context, keys ...string) ([]T, []string, error) {
+	var vals []T
+	notFound, err := t.cache.MGetCtx(ctx, keys, &vals)
+	return vals, notFound, err
+}
+
+func (t Typed[T]) Set(ctx context.Context, key string, val T) error {
+	return t.cache.SetCtx(ctx, key, val)
+}
+
+func (t Typed[T]) SetWithExpire(ctx context.Context, key string, val T, expire time.Duration) error {
+	return t.cache.SetWithExpireCtx(ctx, key, val, expire)
+}
+
+// Take returns the cached value of key, if not found, loader is called and the result is cached.
+func (t Typed[T]) Take(ctx context.Context, key string, loader func(ctx context.Context) (T, error)) (T, error) {
+	var val T
+	err := t.cache.TakeCtx(ctx, &val, key, func(v any) error {
+		loaded, err := loader(ctx)
+		if err != nil {
+			return err
+		}
+		*v.(*T) = loaded
+		return nil
+	})
+	return val, err
+}
+
+// TakeWithExpire is like Take, loader receives the expire used to cache the result.
+func (t Typed[T]) TakeWithExpire(ctx context.Context, key string, loader func(ctx context.Context, expire time.Duration) (T, error)) (T, error) {
+	var val T
+	err := t.cache.TakeWithExpireCtx(ctx, &val, key, func(v any, expire time.Duration) error {
+		loaded, err := loader(ctx, expire)
+		if err != nil {
+			return err
+		}
+		*v.(*T) = loaded
+		return nil
+	})
+	return val, err
+}
+
+func (t Typed[T]) Del(ctx context.Context, keys ...string) error {
+	return t.cache.DelCtx(ctx, keys...)
+}
+
+func (t Typed[T]) IsNotFound(err error) bool {
+	return t.cache.IsNotFound(err)
+}
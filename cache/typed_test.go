@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type typedUser struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestTyped(t *testing.T) {
+	errNotFound := errors.New("not found")
+	users := NewTyped[typedUser](NewSyncMap(errNotFound))
+
+	err := users.Set(context.Background(), "user:1", typedUser{Id: 1, Name: "jaronnie"})
+	assert.NoError(t, err)
+
+	user, err := users.Get(context.Background(), "user:1")
+	assert.NoError(t, err)
+	assert.Equal(t, typedUser{Id: 1, Name: "jaronnie"}, user)
+
+	var calls int
+	loader := func(ctx context.Context) (typedUser, error) {
+		calls++
+		return typedUser{Id: 2, Name: "gocloudcoder"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		user, err = users.Take(context.Background(), "user:2", loader)
+		assert.NoError(t, err)
+		assert.Equal(t, typedUser{Id: 2, Name: "gocloudcoder"}, user)
+	}
+	assert.Equal(t, 1, calls)
+
+	_, err = users.Take(context.Background(), "user:3", func(ctx context.Context) (typedUser, error) {
+		return typedUser{}, errNotFound
+	})
+	assert.True(t, users.IsNotFound(err))
+
+	vals, notFound, err := users.MGet(context.Background(), "user:1", "user:3", "user:2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user:3"}, notFound)
+	assert.Equal(t, []typedUser{{Id: 1, Name: "jaronnie"}, {}, {Id: 2, Name: "gocloudcoder"}}, vals)
+
+	assert.NoError(t, users.Del(context.Background(), "user:1"))
+	_, err = users.Get(context.Background(), "user:1")
+	assert.True(t, users.IsNotFound(err))
+}
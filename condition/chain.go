@@ -105,6 +105,7 @@ func (c Chain) NotLike(field string, value any, op ...opts.Opt[ChainOperatorOpts
 	return c.addChain(field, NotLike, value, op...)
 }
 
+// In adds field IN (values), values is a slice or a subquery such as *sqlbuilder.SelectBuilder, see Subquery.
 func (c Chain) In(field string, values any, op ...opts.Opt[ChainOperatorOpts]) Chain {
 	return c.addChain(field, In, values, op...)
 }
@@ -117,6 +118,29 @@ func (c Chain) Between(field string, value any, op ...opts.Opt[ChainOperatorOpts
 	return c.addChain(field, Between, value, op...)
 }
 
+func (c Chain) IsNull(field string, op ...opts.Opt[ChainOperatorOpts]) Chain {
+	return c.addChain(field, IsNull, nil, op...)
+}
+
+func (c Chain) IsNotNull(field string, op ...opts.Opt[ChainOperatorOpts]) Chain {
+	return c.addChain(field, IsNotNull, nil, op...)
+}
+
+// Exists adds EXISTS (subquery), subquery is a builder such as *sqlbuilder.SelectBuilder, see Subquery.
+func (c Chain) Exists(subquery any, op ...opts.Opt[ChainOperatorOpts]) Chain {
+	return c.addChain("", Exists, subquery, op...)
+}
+
+func (c Chain) NotExists(subquery any, op ...opts.Opt[ChainOperatorOpts]) Chain {
+	return c.addChain("", NotExists, subquery, op...)
+}
+
+// Expr adds a raw expression, every ? in expr is bound to an arg in order, such as Expr("a + b > ?", 10).
+// Write ?? for a literal ?, such as Expr("tags ?? ?", "vip") with the jsonb ? operator of PostgreSQL.
+func (c Chain) Expr(expr string, args ...any) Chain {
+	return c.addChain(expr, Expr, args)
+}
+
 func (c Chain) Or(fields []string, operators []Operator, values []any, op ...opts.Opt[ChainOperatorOpts]) Chain {
 	o := opts.DefaultApply(op...)
 	c.conditions = append(c.conditions, Condition{
@@ -131,6 +155,30 @@ func (c Chain) Or(fields []string, operators []Operator, values []any, op ...opt
 	return c
 }
 
+// AndGroup adds a group joining chains by AND, the conditions of every chain are joined by AND as well.
+// The chains only carry where conditions, SelectE returns ErrNotWhereCondition for Limit, Offset, OrderBy,
+// GroupBy, Join and WhereClause in them.
+func (c Chain) AndGroup(chains ...Chain) Chain {
+	c.conditions = append(c.conditions, And(chainGroups(chains)...))
+	return c
+}
+
+// OrGroup adds a group joining chains by OR, the conditions of every chain are joined by AND,
+// such as (a = 1 AND b > 2) OR (c IN (3, 4)). The chains only carry where conditions like AndGroup.
+func (c Chain) OrGroup(chains ...Chain) Chain {
+	c.conditions = append(c.conditions, Or(chainGroups(chains)...))
+	return c
+}
+
+func chainGroups(chains []Chain) []Condition {
+	groups := make([]Condition, 0, len(chains))
+	for _, chain := range chains {
+		groups = append(groups, And(chain.conditions...))
+	}
+	return groups
+}
+
+// OrderBy adds value to ORDER BY as it is, use OrderByWhitelist for a sort spec from the client.
 func (c Chain) OrderBy(value any, op ...opts.Opt[ChainOperatorOpts]) Chain {
 	return c.addChain("", OrderBy, value, op...)
 }
@@ -163,6 +211,16 @@ func (c Chain) Join(option sqlbuilder.JoinOption, table string, onExpr ...string
 	return c
 }
 
+// Subquery builds SELECT cols FROM table with the conditions of c, to be the value of In, NotIn and Exists.
+// Its args are merged into the outer statement, and its placeholders follow the flavor of the outer statement.
+func (c Chain) Subquery(table string, cols ...string) *sqlbuilder.SelectBuilder {
+	if len(cols) == 0 {
+		cols = []string{"*"}
+	}
+	sb := Select(*sqlbuilder.NewSelectBuilder().Select(cols...).From(table), c.conditions...)
+	return &sb
+}
+
 func (c Chain) Build() []Condition {
 	return c.conditions
 }
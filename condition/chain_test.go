@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/huandu/go-sqlbuilder"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestChain(t *testing.T) {
@@ -51,3 +52,94 @@ func TestChainJoin(t *testing.T) {
 	fmt.Println(sql)
 	fmt.Println(args)
 }
+
+func TestChainGroup(t *testing.T) {
+	sqlbuilder.DefaultFlavor = sqlbuilder.MySQL
+
+	sb := sqlbuilder.NewSelectBuilder().Select("id").From("user")
+	conds := NewChain().
+		Equal("status", 1).
+		OrGroup(
+			NewChain().Equal("a", 1).GreaterThan("b", 2),
+			NewChain().In("c", []int{3, 4}).AndGroup(NewChain().Equal("d", 5, WithSkip(true))),
+		).
+		Build()
+	builder := Select(*sb, conds...)
+	sql, args := builder.Build()
+	assert.Equal(t, "SELECT id FROM user WHERE status = ? AND ((a = ? AND b > ?) OR (c IN (?, ?)))", sql)
+	assert.Equal(t, []any{1, 1, 2, 3, 4}, args)
+
+	// a group only carries where conditions
+	_, err := SelectE(*sqlbuilder.NewSelectBuilder().Select("id").From("user"), NewChain().
+		OrGroup(NewChain().Equal("a", 1).Limit(10), NewChain().Equal("b", 2)).
+		Build()...)
+	assert.ErrorIs(t, err, ErrNotWhereCondition)
+	assert.ErrorIs(t, Validate(NewChain().AndGroup(NewChain().OrderBy("id desc")).Build()...), ErrNotWhereCondition)
+	assert.NoError(t, Validate(NewChain().AndGroup(NewChain().Limit(10, WithSkip(true))).Build()...))
+}
+
+func TestChainNullExistsExpr(t *testing.T) {
+	sqlbuilder.DefaultFlavor = sqlbuilder.MySQL
+
+	sub := sqlbuilder.NewSelectBuilder()
+	sub.Select("1").From("orders").Where("orders.user_id = user.id", sub.Equal("orders.status", "paid"))
+
+	sb := sqlbuilder.NewSelectBuilder().Select("id").From("user")
+	conds := NewChain().
+		IsNull("deleted_at").
+		IsNotNull("email", WithSkip(true)).
+		NotExists(sub).
+		Expr("JSON_CONTAINS(tags, ?)", `"vip"`).
+		Expr("created_at < NOW()").
+		Build()
+	builder := Select(*sb, conds...)
+	sql, args := builder.Build()
+	assert.Equal(t, "SELECT id FROM user WHERE deleted_at IS NULL AND "+
+		"NOT EXISTS (SELECT 1 FROM orders WHERE orders.user_id = user.id AND orders.status = ?) AND "+
+		"JSON_CONTAINS(tags, ?) AND created_at < NOW()", sql)
+	assert.Equal(t, []any{"paid", `"vip"`}, args)
+}
+
+func TestChainSubquery(t *testing.T) {
+	sqlbuilder.DefaultFlavor = sqlbuilder.MySQL
+
+	users := sqlbuilder.NewSelectBuilder()
+	users.Select("id").From("users").Where(users.Equal("status", 1))
+
+	conds := NewChain().
+		Equal("type", "order").
+		In("user_id", users).
+		NotIn("shop_id", NewChain().Equal("closed", true).Subquery("shops", "id")).
+		Exists(NewChain().Expr("items.order_id = orders.id").GreaterThan("items.price", 100).Subquery("items", "1")).
+		Limit(10).
+		Build()
+	sb := sqlbuilder.NewSelectBuilder().Select("id").From("orders")
+	builder := Select(*sb, conds...)
+
+	for _, tc := range []struct {
+		flavor sqlbuilder.Flavor
+		sql    string
+	}{
+		{sqlbuilder.MySQL, "SELECT id FROM orders WHERE type = ? AND user_id IN (SELECT id FROM users WHERE status = ?) AND " +
+			"shop_id NOT IN (SELECT id FROM shops WHERE closed = ?) AND " +
+			"EXISTS (SELECT 1 FROM items WHERE items.order_id = orders.id AND items.price > ?) LIMIT 10"},
+		{sqlbuilder.SQLite, "SELECT id FROM orders WHERE type = ? AND user_id IN (SELECT id FROM users WHERE status = ?) AND " +
+			"shop_id NOT IN (SELECT id FROM shops WHERE closed = ?) AND " +
+			"EXISTS (SELECT 1 FROM items WHERE items.order_id = orders.id AND items.price > ?) LIMIT 10"},
+		{sqlbuilder.PostgreSQL, "SELECT id FROM orders WHERE type = $1 AND user_id IN (SELECT id FROM users WHERE status = $2) AND " +
+			"shop_id NOT IN (SELECT id FROM shops WHERE closed = $3) AND " +
+			"EXISTS (SELECT 1 FROM items WHERE items.order_id = orders.id AND items.price > $4) LIMIT 10"},
+	} {
+		sql, args := builder.BuildWithFlavor(tc.flavor)
+		assert.Equal(t, tc.sql, sql, tc.flavor.String())
+		assert.Equal(t, []any{"order", 1, true, 100}, args, tc.flavor.String())
+	}
+
+	// a subquery by value is accepted as well
+	builder = Select(*sb, NewChain().NotExists(*users).Build()...)
+	sql, args := builder.Build()
+	assert.Equal(t, "SELECT id FROM orders WHERE NOT EXISTS (SELECT id FROM users WHERE status = ?)", sql)
+	assert.Equal(t, []any{1}, args)
+
+	assert.Error(t, Validate(NewChain().Exists([]int{1}).Build()...))
+}
@@ -1,10 +1,13 @@
 package condition
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/huandu/go-sqlbuilder"
 	"github.com/spf13/cast"
+	"github.com/zeromicro/go-zero/core/logx"
 
 	"github.com/jzero-io/jzero-contrib/castx"
 )
@@ -33,8 +36,26 @@ const (
 	OrderBy          Operator = "ORDER BY"
 	GroupBy          Operator = "GROUP BY"
 	Join             Operator = "JOIN"
+	IsNull           Operator = "IS NULL"
+	IsNotNull        Operator = "IS NOT NULL"
+	// Exists takes a subquery such as *sqlbuilder.SelectBuilder as Value, see Chain.Subquery.
+	Exists    Operator = "EXISTS"
+	NotExists Operator = "NOT EXISTS"
+	// Expr takes a raw expression with ? placeholders as Field and the args as Value, ?? is a literal ?,
+	// which is required for ? in string literals and operators such as the jsonb ? of PostgreSQL.
+	Expr Operator = "EXPR"
 )
 
+// ErrUnknownOperator is returned by Validate for an operator which is not defined in this package.
+var ErrUnknownOperator = errors.New("condition: unknown operator")
+
+// ErrNotWhereCondition is returned by Validate for Limit, Offset, OrderBy, GroupBy, Join or WhereClause in a group,
+// which only carries where conditions.
+var ErrNotWhereCondition = errors.New("condition: not a where condition in group")
+
+// matchNothing replaces invalid conditions, so Select, Update and Delete fail closed.
+const matchNothing = "1 = 0"
+
 type Condition struct {
 	// Skip indicates whether the condition is effective.
 	Skip bool
@@ -42,7 +63,7 @@ type Condition struct {
 	// SkipFunc The priority is higher than Skip.
 	SkipFunc func() bool
 
-	// Or indicates an or condition
+	// Or indicates an or condition, or joins Group by OR
 	Or bool
 
 	OrOperators  []Operator
@@ -63,6 +84,9 @@ type Condition struct {
 	JoinCondition
 
 	WhereClause *sqlbuilder.WhereClause
+
+	// Group nests conditions, they are joined by AND, or by OR if Or is set. Use And and Or to build it.
+	Group []Condition
 }
 
 type JoinCondition struct {
@@ -75,47 +99,133 @@ func New(conditions ...Condition) []Condition {
 	return conditions
 }
 
-func buildExpr(cond *sqlbuilder.Cond, field string, operator Operator, value any) string {
-	switch operator {
+// And groups conditions joined by AND, such as (a = 1 AND b > 2), groups nest arbitrarily deep.
+// A group only carries where conditions, Limit, Offset, OrderBy, GroupBy, Join and WhereClause are rejected,
+// see Validate. Add a WhereClause at the top level instead.
+func And(conditions ...Condition) Condition {
+	return Condition{Group: conditions}
+}
+
+// Or groups conditions joined by OR, such as (a = 1 OR b > 2), groups nest arbitrarily deep.
+func Or(conditions ...Condition) Condition {
+	return Condition{Or: true, Group: conditions}
+}
+
+// Validate reports the first invalid condition, such as an unknown operator, which SelectE, UpdateE and DeleteE return.
+func Validate(conditions ...Condition) error {
+	_, err := whereClause(conditions...)
+	return err
+}
+
+// buildExpr builds the where expr of operator, it is empty for the operators which are not in where clause.
+func buildExpr(cond *sqlbuilder.Cond, field string, operator Operator, value any) (string, error) {
+	switch Operator(strings.ToUpper(string(operator))) {
 	case Equal:
-		return cond.Equal(field, value)
+		return cond.Equal(field, value), nil
 	case NotEqual:
-		return cond.NotEqual(field, value)
+		return cond.NotEqual(field, value), nil
 	case GreaterThan:
-		return cond.GreaterThan(field, value)
+		return cond.GreaterThan(field, value), nil
 	case LessThan:
-		return cond.LessThan(field, value)
+		return cond.LessThan(field, value), nil
 	case GreaterEqualThan:
-		return cond.GreaterEqualThan(field, value)
+		return cond.GreaterEqualThan(field, value), nil
 	case LessEqualThan:
-		return cond.LessEqualThan(field, value)
+		return cond.LessEqualThan(field, value), nil
 	case In:
+		if sub, ok := subquery(value); ok {
+			return cond.In(field, sub), nil
+		}
 		if len(castx.ToSlice(value)) == 0 {
 			// if value is empty, force placeholder nil to avoid sql error
-			return cond.In(field, nil)
+			return cond.In(field, nil), nil
 		}
-		return cond.In(field, castx.ToSlice(value)...)
+		return cond.In(field, castx.ToSlice(value)...), nil
 	case NotIn:
+		if sub, ok := subquery(value); ok {
+			return cond.NotIn(field, sub), nil
+		}
 		if len(castx.ToSlice(value)) == 0 {
 			// if value is empty, force placeholder nil to avoid sql error
-			return cond.NotIn(field, nil)
+			return cond.NotIn(field, nil), nil
 		}
-		return cond.NotIn(field, castx.ToSlice(value)...)
+		return cond.NotIn(field, castx.ToSlice(value)...), nil
 	case Like:
-		return cond.Like(field, value)
+		return cond.Like(field, value), nil
 	case NotLike:
-		return cond.NotLike(field, value)
-	case Between:
-		v := castx.ToSlice(value)
-		return cond.Between(field, v[0], v[1])
-	case NotBetween:
+		return cond.NotLike(field, value), nil
+	case Between, NotBetween:
 		v := castx.ToSlice(value)
-		return cond.NotBetween(field, v[0], v[1])
+		if len(v) != 2 {
+			return "", fmt.Errorf("condition: %s takes 2 values, got %v", operator, value)
+		}
+		if Operator(strings.ToUpper(string(operator))) == Between {
+			return cond.Between(field, v[0], v[1]), nil
+		}
+		return cond.NotBetween(field, v[0], v[1]), nil
+	case IsNull:
+		return cond.IsNull(field), nil
+	case IsNotNull:
+		return cond.IsNotNull(field), nil
+	case Exists, NotExists:
+		sub, ok := subquery(value)
+		if !ok {
+			return "", fmt.Errorf("condition: %s takes a subquery, got %T", operator, value)
+		}
+		if Operator(strings.ToUpper(string(operator))) == Exists {
+			return cond.Exists(sub), nil
+		}
+		return cond.NotExists(sub), nil
+	case Expr:
+		return buildRawExpr(cond, field, castx.ToSlice(value))
+	case Limit, Offset, OrderBy, GroupBy, Join, "":
+		return "", nil
 	}
-	return ""
+	return "", fmt.Errorf("%w: %q", ErrUnknownOperator, operator)
 }
 
-func whereClause(conditions ...Condition) *sqlbuilder.WhereClause {
+// subquery returns value as a subquery builder, its args are merged into the outer statement
+// and its placeholders follow the flavor of the outer statement.
+func subquery(value any) (sqlbuilder.Builder, bool) {
+	switch v := value.(type) {
+	case sqlbuilder.SelectBuilder:
+		return &v, true
+	case sqlbuilder.Builder:
+		return v, true
+	}
+	return nil, false
+}
+
+// buildRawExpr binds args to the ? placeholders of expr in order, ?? is a literal ?.
+func buildRawExpr(cond *sqlbuilder.Cond, expr string, args []any) (string, error) {
+	var (
+		sb strings.Builder
+		n  int
+	)
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case expr[i] == '$':
+			// $ is the placeholder prefix of sqlbuilder
+			sb.WriteString("$$")
+		case expr[i] != '?':
+			sb.WriteByte(expr[i])
+		case i+1 < len(expr) && expr[i+1] == '?':
+			sb.WriteByte('?')
+			i++
+		default:
+			if n < len(args) {
+				sb.WriteString(cond.Var(args[n]))
+			}
+			n++
+		}
+	}
+	if n != len(args) {
+		return "", fmt.Errorf("condition: expr %q has %d placeholders but %d args", expr, n, len(args))
+	}
+	return sb.String(), nil
+}
+
+func whereClause(conditions ...Condition) (*sqlbuilder.WhereClause, error) {
 	clause := sqlbuilder.NewWhereClause()
 	cond := sqlbuilder.NewCond()
 
@@ -130,33 +240,101 @@ func whereClause(conditions ...Condition) *sqlbuilder.WhereClause {
 			clause.AddWhereClause(c.WhereClause)
 			continue
 		}
-		if c.Or {
-			if c.OrValuesFunc != nil {
-				c.OrValues = c.OrValuesFunc()
+		expr, err := buildConditionExpr(cond, c)
+		if err != nil {
+			return nil, err
+		}
+		if expr != "" {
+			clause.AddWhereExpr(cond.Args, expr)
+		}
+	}
+	return clause, nil
+}
+
+// buildConditionExpr builds the expr of a where condition which is not skipped, groups are built recursively.
+func buildConditionExpr(cond *sqlbuilder.Cond, c Condition) (string, error) {
+	if c.Group != nil {
+		var expr []string
+		for _, g := range c.Group {
+			if g.SkipFunc != nil {
+				g.Skip = g.SkipFunc()
 			}
-			var expr []string
-			for i, field := range c.OrFields {
-				if or := buildExpr(cond, field, c.OrOperators[i], c.OrValues[i]); or != "" {
-					expr = append(expr, or)
+			if g.Skip {
+				continue
+			}
+			if g.WhereClause != nil {
+				// a where clause is only added to the top level, it would be dropped silently
+				return "", fmt.Errorf("%w: WhereClause", ErrNotWhereCondition)
+			}
+			if g.Group == nil && !g.Or {
+				switch op := Operator(strings.ToUpper(string(g.Operator))); op {
+				case Limit, Offset, OrderBy, GroupBy, Join:
+					// Select only applies them at the top level, they would be dropped silently
+					return "", fmt.Errorf("%w: %s", ErrNotWhereCondition, op)
 				}
 			}
-			if len(expr) > 0 {
-				clause.AddWhereExpr(cond.Args, cond.Or(expr...))
+			e, err := buildConditionExpr(cond, g)
+			if err != nil {
+				return "", err
+			}
+			if e != "" {
+				expr = append(expr, e)
 			}
-		} else {
-			if c.ValueFunc != nil {
-				c.Value = c.ValueFunc()
+		}
+		switch {
+		case len(expr) == 0:
+			return "", nil
+		case c.Or:
+			return cond.Or(expr...), nil
+		default:
+			return cond.And(expr...), nil
+		}
+	}
+
+	if c.Or {
+		if c.OrValuesFunc != nil {
+			c.OrValues = c.OrValuesFunc()
+		}
+		var expr []string
+		for i, field := range c.OrFields {
+			or, err := buildExpr(cond, field, c.OrOperators[i], c.OrValues[i])
+			if err != nil {
+				return "", err
 			}
-			if and := buildExpr(cond, c.Field, c.Operator, c.Value); and != "" {
-				clause.AddWhereExpr(cond.Args, and)
+			if or != "" {
+				expr = append(expr, or)
 			}
 		}
+		if len(expr) == 0 {
+			return "", nil
+		}
+		return cond.Or(expr...), nil
 	}
-	return clause
+
+	if c.ValueFunc != nil {
+		c.Value = c.ValueFunc()
+	}
+	return buildExpr(cond, c.Field, c.Operator, c.Value)
 }
 
+// Select adds conditions to sb. Invalid conditions, such as an unknown operator, are logged and
+// make the statement match no rows, since dropping them may select every row. Use SelectE to get the error.
 func Select(sb sqlbuilder.SelectBuilder, conditions ...Condition) sqlbuilder.SelectBuilder {
-	clause := whereClause(conditions...)
+	b, err := SelectE(sb, conditions...)
+	if err != nil {
+		logx.Error(err)
+		sb.Where(matchNothing)
+		return sb
+	}
+	return b
+}
+
+// SelectE adds conditions to sb, it returns the error of invalid conditions, see Validate.
+func SelectE(sb sqlbuilder.SelectBuilder, conditions ...Condition) (sqlbuilder.SelectBuilder, error) {
+	clause, err := whereClause(conditions...)
+	if err != nil {
+		return sb, err
+	}
 	for _, c := range conditions {
 		if c.SkipFunc != nil {
 			c.Skip = c.SkipFunc()
@@ -183,11 +361,27 @@ func Select(sb sqlbuilder.SelectBuilder, conditions ...Condition) sqlbuilder.Sel
 	if clause != nil {
 		sb = *sb.AddWhereClause(clause)
 	}
-	return sb
+	return sb, nil
 }
 
+// Update adds conditions to builder. Invalid conditions, such as an unknown operator, are logged and
+// make the statement match no rows, since dropping them may update every row. Use UpdateE to get the error.
 func Update(builder sqlbuilder.UpdateBuilder, conditions ...Condition) sqlbuilder.UpdateBuilder {
-	clause := whereClause(conditions...)
+	b, err := UpdateE(builder, conditions...)
+	if err != nil {
+		logx.Error(err)
+		builder.Where(matchNothing)
+		return builder
+	}
+	return b
+}
+
+// UpdateE adds conditions to builder, it returns the error of invalid conditions, see Validate.
+func UpdateE(builder sqlbuilder.UpdateBuilder, conditions ...Condition) (sqlbuilder.UpdateBuilder, error) {
+	clause, err := whereClause(conditions...)
+	if err != nil {
+		return builder, err
+	}
 	for _, c := range conditions {
 		if c.SkipFunc != nil {
 			c.Skip = c.SkipFunc()
@@ -210,11 +404,27 @@ func Update(builder sqlbuilder.UpdateBuilder, conditions ...Condition) sqlbuilde
 	if clause != nil {
 		builder = *builder.AddWhereClause(clause)
 	}
-	return builder
+	return builder, nil
 }
 
+// Delete adds conditions to builder. Invalid conditions, such as an unknown operator, are logged and
+// make the statement match no rows, since dropping them may delete every row. Use DeleteE to get the error.
 func Delete(builder sqlbuilder.DeleteBuilder, conditions ...Condition) sqlbuilder.DeleteBuilder {
-	clause := whereClause(conditions...)
+	b, err := DeleteE(builder, conditions...)
+	if err != nil {
+		logx.Error(err)
+		builder.Where(matchNothing)
+		return builder
+	}
+	return b
+}
+
+// DeleteE adds conditions to builder, it returns the error of invalid conditions, see Validate.
+func DeleteE(builder sqlbuilder.DeleteBuilder, conditions ...Condition) (sqlbuilder.DeleteBuilder, error) {
+	clause, err := whereClause(conditions...)
+	if err != nil {
+		return builder, err
+	}
 	for _, c := range conditions {
 		if c.SkipFunc != nil {
 			c.Skip = c.SkipFunc()
@@ -235,5 +445,5 @@ func Delete(builder sqlbuilder.DeleteBuilder, conditions ...Condition) sqlbuilde
 	if clause != nil {
 		builder = *builder.AddWhereClause(clause)
 	}
-	return builder
+	return builder, nil
 }
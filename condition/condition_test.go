@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/huandu/go-sqlbuilder"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestSelectWithCondition(t *testing.T) {
@@ -130,7 +131,8 @@ func TestWhereClause(t *testing.T) {
 			return []any{[]int{24, 49}, []int{170, 176}}
 		},
 	})
-	clause := whereClause(cds...)
+	clause, err := whereClause(cds...)
+	assert.NoError(t, err)
 	statement, args := clause.Build()
 	fmt.Println(statement)
 	fmt.Println(args)
@@ -197,3 +199,162 @@ func TestChain_In(t *testing.T) {
 		fmt.Println(sql, args)
 	})
 }
+
+func TestGroupCondition(t *testing.T) {
+	sqlbuilder.DefaultFlavor = sqlbuilder.MySQL
+
+	cds := New(Condition{
+		Field:    "status",
+		Operator: Equal,
+		Value:    1,
+	}, Or(
+		And(Condition{
+			Field:    "a",
+			Operator: Equal,
+			Value:    1,
+		}, Condition{
+			Field:    "b",
+			Operator: GreaterThan,
+			Value:    2,
+		}),
+		And(Condition{
+			Field:    "c",
+			Operator: In,
+			Value:    []int{3, 4},
+		}, Or(Condition{
+			Field:    "d",
+			Operator: Equal,
+			Value:    5,
+		}, Condition{
+			Skip:     true,
+			Field:    "e",
+			Operator: Equal,
+			Value:    6,
+		})),
+		// empty groups are dropped
+		And(Condition{
+			Skip:     true,
+			Field:    "f",
+			Operator: Equal,
+			Value:    7,
+		}),
+	))
+
+	sb := sqlbuilder.NewSelectBuilder().Select("id").From("user")
+	builder := Select(*sb, cds...)
+	sql, args := builder.Build()
+	assert.Equal(t, "SELECT id FROM user WHERE status = ? AND ((a = ? AND b > ?) OR (c IN (?, ?) AND (d = ?)))", sql)
+	assert.Equal(t, []any{1, 1, 2, 3, 4, 5}, args)
+
+	ub := sqlbuilder.NewUpdateBuilder().Update("user")
+	ub.Set(ub.Assign("name", "jaronnie"))
+	updateBuilder := Update(*ub, cds...)
+	sql, args = updateBuilder.Build()
+	assert.Equal(t, "UPDATE user SET name = ? WHERE status = ? AND ((a = ? AND b > ?) OR (c IN (?, ?) AND (d = ?)))", sql)
+	assert.Equal(t, []any{"jaronnie", 1, 1, 2, 3, 4, 5}, args)
+
+	db := sqlbuilder.NewDeleteBuilder().DeleteFrom("user")
+	deleteBuilder := Delete(*db, cds...)
+	sql, args = deleteBuilder.Build()
+	assert.Equal(t, "DELETE FROM user WHERE status = ? AND ((a = ? AND b > ?) OR (c IN (?, ?) AND (d = ?)))", sql)
+	assert.Equal(t, []any{1, 1, 2, 3, 4, 5}, args)
+
+	// a group of skipped conditions only
+	builder = Select(*sb, And(Condition{Skip: true, Field: "a", Operator: Equal, Value: 1}))
+	sql, _ = builder.Build()
+	assert.NotContains(t, sql, "WHERE")
+}
+
+func TestNullExistsExprCondition(t *testing.T) {
+	sqlbuilder.DefaultFlavor = sqlbuilder.MySQL
+
+	sub := sqlbuilder.NewSelectBuilder()
+	sub.Select("1").From("orders").Where("orders.user_id = user.id", sub.GreaterThan("orders.amount", 100))
+
+	sb := sqlbuilder.NewSelectBuilder().Select("id").From("user")
+	builder := Select(*sb, New(
+		Condition{Field: "deleted_at", Operator: IsNull},
+		Condition{Field: "email", Operator: "is not null"},
+		Condition{Operator: Exists, Value: sub},
+		Condition{Field: "a + b > ? AND price < ? * $rate", Operator: Expr, Value: []any{10, 2}},
+	)...)
+	sql, args := builder.Build()
+	assert.Equal(t, "SELECT id FROM user WHERE deleted_at IS NULL AND email IS NOT NULL AND "+
+		"EXISTS (SELECT 1 FROM orders WHERE orders.user_id = user.id AND orders.amount > ?) AND a + b > ? AND price < ? * $rate", sql)
+	assert.Equal(t, []any{100, 10, 2}, args)
+
+	// placeholders follow the flavor
+	sql, args = builder.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	assert.Equal(t, "SELECT id FROM user WHERE deleted_at IS NULL AND email IS NOT NULL AND "+
+		"EXISTS (SELECT 1 FROM orders WHERE orders.user_id = user.id AND orders.amount > $1) AND a + b > $2 AND price < $3 * $rate", sql)
+	assert.Equal(t, []any{100, 10, 2}, args)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(
+		Condition{Field: "a", Operator: Equal, Value: 1},
+		Condition{Operator: Limit, Value: 10},
+		Condition{Operator: OrderBy, Value: "id desc"},
+	))
+
+	err := Validate(Or(Condition{Field: "a", Operator: Equal, Value: 1}, Condition{Field: "b", Operator: "~=", Value: 2}))
+	assert.ErrorIs(t, err, ErrUnknownOperator)
+	assert.ErrorContains(t, err, `"~="`)
+
+	err = Validate(Condition{Or: true, OrFields: []string{"a"}, OrOperators: []Operator{"EQ"}, OrValues: []any{1}})
+	assert.ErrorIs(t, err, ErrUnknownOperator)
+
+	assert.Error(t, Validate(Condition{Field: "a > ? AND b < ?", Operator: Expr, Value: []any{1}}))
+	assert.Error(t, Validate(Condition{Field: "created_at", Operator: Between, Value: []string{"2024-01-01"}}))
+
+	// an unknown operator must not be dropped from the where clause
+	db := sqlbuilder.NewDeleteBuilder().DeleteFrom("user")
+	_, err = DeleteE(*db, Condition{Field: "id", Operator: "==", Value: 1})
+	assert.ErrorIs(t, err, ErrUnknownOperator)
+
+	builder := Delete(*db, Condition{Field: "id", Operator: "==", Value: 1})
+	sql, _ := builder.Build()
+	assert.Equal(t, "DELETE FROM user WHERE 1 = 0", sql)
+
+	sb := sqlbuilder.NewSelectBuilder().Select("id").From("user")
+	_, err = SelectE(*sb, Condition{Field: "a > ?", Operator: Expr, Value: []any{1, 2}})
+	assert.Error(t, err)
+	selected := Select(*sb, Condition{Field: "a > ?", Operator: Expr, Value: []any{1, 2}})
+	sql, _ = selected.Build()
+	assert.Equal(t, "SELECT id FROM user WHERE 1 = 0", sql)
+}
+
+func TestNestedWhereClause(t *testing.T) {
+	wc := sqlbuilder.NewWhereClause()
+	cond := sqlbuilder.NewCond()
+	wc.AddWhereExpr(cond.Args, cond.Equal("tenant_id", 7))
+
+	// a nested where clause must not be dropped, the statement would touch every row
+	ub := sqlbuilder.NewUpdateBuilder().Update("user")
+	ub.Set(ub.Assign("x", 1))
+	_, err := UpdateE(*ub, Or(Condition{WhereClause: wc}))
+	assert.ErrorIs(t, err, ErrNotWhereCondition)
+
+	db := sqlbuilder.NewDeleteBuilder().DeleteFrom("user")
+	_, err = DeleteE(*db, And(Condition{WhereClause: wc}, Condition{Field: "a", Operator: Equal, Value: 1}))
+	assert.ErrorIs(t, err, ErrNotWhereCondition)
+	builder := Delete(*db, And(Condition{WhereClause: wc}, Condition{Field: "a", Operator: Equal, Value: 1}))
+	sql, _ := builder.Build()
+	assert.Equal(t, "DELETE FROM user WHERE 1 = 0", sql)
+
+	// a where clause at the top level is kept
+	builder, err = DeleteE(*sqlbuilder.NewDeleteBuilder().DeleteFrom("user"), Condition{WhereClause: wc})
+	assert.NoError(t, err)
+	sql, args := builder.Build()
+	assert.Equal(t, "DELETE FROM user WHERE tenant_id = ?", sql)
+	assert.Equal(t, []any{7}, args)
+}
+
+func TestExprEscape(t *testing.T) {
+	sb := sqlbuilder.NewSelectBuilder().Select("id").From("user")
+	builder, err := SelectE(*sb, NewChain().Expr("tags ?? ? AND name <> '??'", "vip").Build()...)
+	assert.NoError(t, err)
+	sql, args := builder.BuildWithFlavor(sqlbuilder.PostgreSQL)
+	assert.Equal(t, "SELECT id FROM user WHERE tags ? $1 AND name <> '?'", sql)
+	assert.Equal(t, []any{"vip"}, args)
+}
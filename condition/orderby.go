@@ -0,0 +1,65 @@
+package condition
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidDirection is returned by OrderByWhitelist for a sort direction other than asc and desc.
+var ErrInvalidDirection = errors.New("condition: invalid sort direction")
+
+// UnknownFieldError is returned by OrderByWhitelist for a sort field which is not allowed.
+type UnknownFieldError struct {
+	Field string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("condition: unknown sort field %q", e.Field)
+}
+
+// OrderByWhitelist parses a sort spec from the client, such as "-created_at,name", into the values of OrderBy,
+// such as []string{"created_at DESC", "name ASC"}. A field is descending with the - prefix, and ascending
+// without a prefix or with the + prefix, it may be followed by asc or desc instead, such as "created_at desc".
+// allowed maps the fields the client is able to sort by to the columns, an empty column is the field itself.
+// Only columns of allowed reach the statement, so the spec is safe to come from the client.
+func OrderByWhitelist(requested string, allowed map[string]string) ([]string, error) {
+	var orderBy []string
+	for _, item := range strings.Split(requested, ",") {
+		parts := strings.Fields(item)
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidDirection, strings.TrimSpace(item))
+		}
+
+		field, direction := parts[0], "ASC"
+		switch {
+		case strings.HasPrefix(field, "-"):
+			field, direction = field[1:], "DESC"
+		case strings.HasPrefix(field, "+"):
+			field = field[1:]
+		}
+		if len(parts) == 2 {
+			if field != parts[0] {
+				// such as "-created_at asc"
+				return nil, fmt.Errorf("%w: %q", ErrInvalidDirection, strings.TrimSpace(item))
+			}
+			direction = strings.ToUpper(parts[1])
+			if direction != "ASC" && direction != "DESC" {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidDirection, parts[1])
+			}
+		}
+
+		column, ok := allowed[field]
+		if !ok {
+			return nil, &UnknownFieldError{Field: field}
+		}
+		if column == "" {
+			column = field
+		}
+		orderBy = append(orderBy, column+" "+direction)
+	}
+	return orderBy, nil
+}
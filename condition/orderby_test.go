@@ -0,0 +1,61 @@
+package condition
+
+import (
+	"testing"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderByWhitelist(t *testing.T) {
+	allowed := map[string]string{
+		"created_at": "",
+		"name":       "user.name",
+		"id":         "user.id",
+	}
+
+	orderBy, err := OrderByWhitelist("-created_at,name", allowed)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"created_at DESC", "user.name ASC"}, orderBy)
+
+	orderBy, err = OrderByWhitelist(" +id , created_at desc,name Asc,", allowed)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user.id ASC", "created_at DESC", "user.name ASC"}, orderBy)
+
+	orderBy, err = OrderByWhitelist("", allowed)
+	assert.NoError(t, err)
+	assert.Empty(t, orderBy)
+
+	sqlbuilder.DefaultFlavor = sqlbuilder.MySQL
+	orderBy, err = OrderByWhitelist("-id", allowed)
+	assert.NoError(t, err)
+	sb := sqlbuilder.NewSelectBuilder().Select("id").From("user")
+	builder := Select(*sb, NewChain().Equal("status", 1).OrderBy(orderBy).Build()...)
+	sql, _ := builder.Build()
+	assert.Equal(t, "SELECT id FROM user WHERE status = ? ORDER BY user.id DESC", sql)
+}
+
+func TestOrderByWhitelistError(t *testing.T) {
+	allowed := map[string]string{"id": "", "name": ""}
+
+	for _, requested := range []string{
+		"password",
+		"id,-password",
+		"(SELECT)",
+		"id;DROP",
+	} {
+		_, err := OrderByWhitelist(requested, allowed)
+		var unknown *UnknownFieldError
+		assert.ErrorAs(t, err, &unknown, requested)
+	}
+
+	for _, requested := range []string{
+		"id sideways",
+		"-id asc",
+		"id desc, name DESC NULLS FIRST",
+		"id desc; DROP TABLE user",
+	} {
+		_, err := OrderByWhitelist(requested, allowed)
+		assert.ErrorIs(t, err, ErrInvalidDirection, requested)
+	}
+}
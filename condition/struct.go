@@ -0,0 +1,246 @@
+package condition
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/zeromicro/go-zero/tools/goctl/util/stringx"
+)
+
+const condTag = "cond"
+
+// struct tag operators which are not where operators
+const (
+	tagPage     = "page"
+	tagPageSize = "pagesize"
+	tagOrderBy  = "orderby"
+)
+
+// ErrInvalidTag is returned by FromStruct for a malformed cond tag.
+var ErrInvalidTag = errors.New("condition: invalid cond tag")
+
+var tagOperators = map[string]Operator{
+	"eq":         Equal,
+	"ne":         NotEqual,
+	"gt":         GreaterThan,
+	"lt":         LessThan,
+	"ge":         GreaterEqualThan,
+	"le":         LessEqualThan,
+	"in":         In,
+	"notin":      NotIn,
+	"like":       Like,
+	"notlike":    NotLike,
+	"between":    Between,
+	"notbetween": NotBetween,
+	"limit":      Limit,
+	"offset":     Offset,
+	"groupby":    GroupBy,
+}
+
+type tagOptions struct {
+	field    string
+	op       string
+	skipZero bool
+	allow    map[string]string
+}
+
+// FromStruct builds conditions from the fields of a struct, or a pointer to struct, tagged with cond.
+// The tag options are separated by comma:
+//
+//	field=name         the column, default the snake case of the field name
+//	op=like            eq (default), ne, gt, lt, ge, le, in, notin, like, notlike, between, notbetween,
+//	                   limit, offset, groupby, page, pagesize and orderby
+//	skipzero           skip the condition when the value is zero, nil or empty
+//	allow=id|name      the fields which orderby is allowed to sort by, required by orderby, a field
+//	                   maps to another column such as allow=created:created_at
+//
+// For example:
+//
+//	type ListRequest struct {
+//		Name   string `cond:"op=like,skipzero"`
+//		Status []int  `cond:"field=status,op=in,skipzero"`
+//		Page   int    `cond:"op=page"`
+//		Size   int    `cond:"op=pagesize"`
+//		Sort   string `cond:"op=orderby,allow=id|created_at,skipzero"`
+//	}
+//
+// between and notbetween take a slice or array of 2 values. page and pagesize add Offset and Limit
+// like Chain.Page, page starts from 1, a string is parsed in base 10. An orderby value is
+// a sort spec such as "-created_at,id" parsed by OrderByWhitelist with allow, since it usually comes from the client.
+// Embedded structs are walked as well, fields without the tag or tagged with "-" are ignored.
+func FromStruct(v any) ([]Condition, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("condition: FromStruct got nil %T", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("condition: FromStruct only accepts structs, got %T", v)
+	}
+
+	var (
+		conditions     []Condition
+		page, pageSize int
+	)
+	if err := walkStruct(rv, func(field reflect.StructField, value reflect.Value, o tagOptions) error {
+		if o.skipZero && isZero(value) {
+			return nil
+		}
+		for value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				// a nil pointer is a missing value
+				return nil
+			}
+			value = value.Elem()
+		}
+
+		var err error
+		switch o.op {
+		case tagPage:
+			if page, err = toInt(value); err != nil {
+				return fmt.Errorf("condition: field %s: %w", field.Name, err)
+			}
+		case tagPageSize:
+			if pageSize, err = toInt(value); err != nil {
+				return fmt.Errorf("condition: field %s: %w", field.Name, err)
+			}
+		case tagOrderBy:
+			orderBy, err := OrderByWhitelist(cast.ToString(value.Interface()), o.allow)
+			if err != nil {
+				return fmt.Errorf("condition: field %s: %w", field.Name, err)
+			}
+			if len(orderBy) > 0 {
+				conditions = append(conditions, Condition{Operator: OrderBy, Value: orderBy})
+			}
+		default:
+			if o.op == "between" || o.op == "notbetween" {
+				if (value.Kind() != reflect.Slice && value.Kind() != reflect.Array) || value.Len() != 2 {
+					return fmt.Errorf("condition: field %s: %s requires 2 values, got %v", field.Name, o.op, value.Interface())
+				}
+			}
+			conditions = append(conditions, Condition{
+				Field:    o.field,
+				Operator: tagOperators[o.op],
+				Value:    value.Interface(),
+			})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if pageSize > 0 {
+		conditions = append(conditions, Condition{
+			Operator: Offset,
+			Value:    (max(page, 1) - 1) * pageSize,
+		}, Condition{
+			Operator: Limit,
+			Value:    pageSize,
+		})
+	}
+	return conditions, nil
+}
+
+// walkStruct calls fn with every tagged field of rv, including the fields of embedded structs.
+func walkStruct(rv reflect.Value, fn func(field reflect.StructField, value reflect.Value, o tagOptions) error) error {
+	typ := rv.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, tagged := field.Tag.Lookup(condTag)
+		if tag == "-" {
+			continue
+		}
+
+		if !tagged {
+			if field.Anonymous {
+				value := rv.Field(i)
+				if value.Kind() == reflect.Ptr {
+					if value.IsNil() {
+						continue
+					}
+					value = value.Elem()
+				}
+				if value.Kind() == reflect.Struct {
+					if err := walkStruct(value, fn); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		o, err := parseTag(field, tag)
+		if err != nil {
+			return err
+		}
+		if err = fn(field, rv.Field(i), o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseTag(field reflect.StructField, tag string) (tagOptions, error) {
+	o := tagOptions{
+		field: stringx.From(field.Name).ToSnake(),
+		op:    "eq",
+	}
+	for _, option := range strings.Split(tag, ",") {
+		option = strings.TrimSpace(option)
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "":
+		case "field":
+			o.field = value
+		case "op":
+			o.op = strings.ToLower(value)
+		case "skipzero":
+			o.skipZero = true
+		case "allow":
+			o.allow = make(map[string]string)
+			for _, allow := range strings.Split(value, "|") {
+				field, column, _ := strings.Cut(allow, ":")
+				o.allow[field] = column
+			}
+		default:
+			return o, fmt.Errorf("%w: field %s: unknown option %q", ErrInvalidTag, field.Name, option)
+		}
+	}
+	if _, ok := tagOperators[o.op]; !ok && o.op != tagPage && o.op != tagPageSize && o.op != tagOrderBy {
+		return o, fmt.Errorf("%w: field %s: unknown op %q", ErrInvalidTag, field.Name, o.op)
+	}
+	if o.field == "" {
+		return o, fmt.Errorf("%w: field %s: empty field", ErrInvalidTag, field.Name)
+	}
+	if o.op == tagOrderBy && len(o.allow) == 0 {
+		return o, fmt.Errorf("%w: field %s: orderby requires allow", ErrInvalidTag, field.Name)
+	}
+	return o, nil
+}
+
+// toInt converts value to int, a string is parsed in base 10, so "08" is 8 rather than invalid octal.
+func toInt(value reflect.Value) (int, error) {
+	if value.Kind() == reflect.String {
+		return strconv.Atoi(strings.TrimSpace(value.String()))
+	}
+	return cast.ToIntE(value.Interface())
+}
+
+// isZero reports whether value is the zero value, a nil pointer or an empty slice or map.
+func isZero(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
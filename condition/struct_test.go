@@ -0,0 +1,101 @@
+package condition
+
+import (
+	"testing"
+
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/stretchr/testify/assert"
+)
+
+type pageRequest struct {
+	Page int    `cond:"op=page"`
+	Size int    `cond:"op=pagesize"`
+	Sort string `cond:"op=orderby,allow=id|created:created_at,skipzero"`
+}
+
+type listRequest struct {
+	pageRequest
+	Name      string   `cond:"op=like,skipzero"`
+	Status    []int    `cond:"op=in,skipzero"`
+	MinAge    *int     `cond:"field=age,op=ge"`
+	CreatedAt []string `cond:"op=between,skipzero"`
+	Keyword   string
+	Ignored   string `cond:"-"`
+}
+
+func TestFromStruct(t *testing.T) {
+	sqlbuilder.DefaultFlavor = sqlbuilder.MySQL
+
+	age := 0
+	req := listRequest{
+		pageRequest: pageRequest{Page: 3, Size: 20, Sort: "-created, id"},
+		Name:        "%jaronnie%",
+		MinAge:      &age,
+		Keyword:     "not a condition",
+		Ignored:     "ignored",
+	}
+	conds, err := FromStruct(&req)
+	assert.NoError(t, err)
+
+	sb := sqlbuilder.NewSelectBuilder().Select("id").From("user")
+	builder := Select(*sb, conds...)
+	sql, args := builder.Build()
+	assert.Equal(t, "SELECT id FROM user WHERE name LIKE ? AND age >= ? ORDER BY created_at DESC, id ASC LIMIT 20 OFFSET 40", sql)
+	assert.Equal(t, []any{"%jaronnie%", 0}, args)
+
+	// zero values are skipped by skipzero, nil pointers are always skipped
+	conds, err = FromStruct(listRequest{Status: []int{1, 2}, CreatedAt: []string{"2024-01-01", "2024-12-31"}})
+	assert.NoError(t, err)
+	builder = Select(*sb, conds...)
+	sql, args = builder.Build()
+	assert.Equal(t, "SELECT id FROM user WHERE status IN (?, ?) AND created_at BETWEEN ? AND ?", sql)
+	assert.Equal(t, []any{1, 2, "2024-01-01", "2024-12-31"}, args)
+
+	// page strings are base 10
+	conds, err = FromStruct(struct {
+		Page string `cond:"op=page"`
+		Size string `cond:"op=pagesize"`
+	}{Page: "08", Size: "10"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Condition{{Operator: Offset, Value: 70}, {Operator: Limit, Value: 10}}, conds)
+}
+
+func TestFromStructError(t *testing.T) {
+	_, err := FromStruct(listRequest{pageRequest: pageRequest{Sort: "password"}})
+	var unknown *UnknownFieldError
+	assert.ErrorAs(t, err, &unknown)
+	assert.Equal(t, "password", unknown.Field)
+
+	_, err = FromStruct(listRequest{pageRequest: pageRequest{Sort: "id; DROP TABLE user"}})
+	assert.Error(t, err)
+
+	_, err = FromStruct(struct {
+		Name string `cond:"op=regexp,skipzero"`
+	}{})
+	assert.ErrorIs(t, err, ErrInvalidTag)
+
+	_, err = FromStruct(struct {
+		Name string `cond:"skipempty"`
+	}{})
+	assert.ErrorIs(t, err, ErrInvalidTag)
+
+	_, err = FromStruct(struct {
+		Sort string `cond:"op=orderby"`
+	}{})
+	assert.ErrorIs(t, err, ErrInvalidTag)
+
+	_, err = FromStruct(listRequest{CreatedAt: []string{"2024-01-01"}})
+	assert.ErrorContains(t, err, "CreatedAt")
+
+	_, err = FromStruct(struct {
+		Page string `cond:"op=page"`
+		Size string `cond:"op=pagesize"`
+	}{Page: "08", Size: "ten"})
+	assert.ErrorContains(t, err, "Size")
+
+	_, err = FromStruct((*listRequest)(nil))
+	assert.Error(t, err)
+
+	_, err = FromStruct("name")
+	assert.Error(t, err)
+}
@@ -1,31 +1,210 @@
 package filelock
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"sync"
 	"syscall"
+	"time"
+
+	"github.com/eddieowens/opts"
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"github.com/jzero-io/jzero-contrib/lock"
+)
+
+const (
+	lockFilePerm = 0o644
+	holderSuffix = ".holder"
+)
+
+var (
+	// ErrNotLocked is returned by Unlock and RUnlock when the lock is not held.
+	ErrNotLocked = errors.New("filelock: not locked")
+
+	// ErrAlreadyLocked is returned when the FileLock already holds the lock, it is not reentrant.
+	ErrAlreadyLocked = errors.New("filelock: already locked")
+
+	// ErrWouldBlock is returned by Lock and RLock when the lock is held by others, it is the error of flock.
+	ErrWouldBlock error = syscall.EWOULDBLOCK
 )
 
-type FileLock struct {
-	f *os.File
+var _ lock.Lock = (*FileLock)(nil)
+
+type (
+	FileLockOpts struct {
+		// Backoff is the wait between two attempts of LockCtx and RLockCtx.
+		Backoff lock.Backoff
+	}
+
+	// FileLock is a flock on a file, the content of the file is left untouched. The exclusive holder
+	// writes its pid and hostname to the file with the .holder suffix if it can, which is removed on Unlock.
+	// A FileLock is held once at a time, acquiring it again before Unlock returns ErrAlreadyLocked.
+	FileLock struct {
+		path    string
+		options FileLockOpts
+
+		mu sync.Mutex
+		// f is open while the lock is held
+		f *os.File
+		// exclusive is set while f holds the exclusive lock
+		exclusive bool
+		// holder is set when the holder file is written by this lock
+		holder bool
+	}
+)
+
+func (opts FileLockOpts) DefaultOptions() FileLockOpts {
+	return FileLockOpts{
+		Backoff: lock.ExponentialBackoff(time.Millisecond, time.Millisecond*100),
+	}
+}
+
+func WithBackoff(backoff lock.Backoff) opts.Opt[FileLockOpts] {
+	return func(o *FileLockOpts) {
+		o.Backoff = backoff
+	}
 }
 
-func New(fp string) (*FileLock, error) {
-	f, err := os.Open(fp)
+// New creates a lock of the file fp, the file is created if missing.
+func New(fp string, op ...opts.Opt[FileLockOpts]) (*FileLock, error) {
+	// flock needs no write access, so read only files can be locked as well
+	f, err := os.OpenFile(fp, os.O_RDONLY|os.O_CREATE, lockFilePerm)
 	if err != nil {
 		return nil, err
 	}
-	return &FileLock{f: f}, nil
+	if err = f.Close(); err != nil {
+		return nil, err
+	}
+
+	o := opts.DefaultApply(op...)
+	if o.Backoff == nil {
+		o.Backoff = o.DefaultOptions().Backoff
+	}
+	return &FileLock{path: fp, options: o}, nil
 }
 
+// Lock acquires the exclusive lock without blocking, it returns ErrWouldBlock when the lock is held by others.
+// Use LockCtx to wait for the lock.
 func (l *FileLock) Lock() error {
-	err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	return failFast(l.TryLock())
+}
+
+// LockCtx blocks until the exclusive lock is acquired or ctx is done.
+func (l *FileLock) LockCtx(ctx context.Context) error {
+	return lock.Retry(ctx, l.options.Backoff, l.TryLock)
+}
+
+func (l *FileLock) TryLock() (bool, error) {
+	return l.tryLock(syscall.LOCK_EX)
+}
+
+// RLock acquires the shared lock without blocking, it returns ErrWouldBlock when the exclusive lock is held
+// by others. Shared holders only exclude exclusive ones. Use RLockCtx to wait for the lock.
+func (l *FileLock) RLock() error {
+	return failFast(l.TryRLock())
+}
+
+// RLockCtx blocks until the shared lock is acquired or ctx is done.
+func (l *FileLock) RLockCtx(ctx context.Context) error {
+	return lock.Retry(ctx, l.options.Backoff, l.TryRLock)
+}
+
+func (l *FileLock) TryRLock() (bool, error) {
+	return l.tryLock(syscall.LOCK_SH)
+}
+
+// Unlock releases the lock and closes the file.
+func (l *FileLock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return ErrNotLocked
+	}
+	defer func() {
+		l.f, l.exclusive, l.holder = nil, false, false
+	}()
+
+	if l.holder {
+		// remove it before unlocking, so it never removes the file of the next holder
+		if err := os.Remove(l.Holder()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			_ = syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+			_ = l.f.Close()
+			return err
+		}
+	}
+	if err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN); err != nil {
+		_ = l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// RUnlock releases the shared lock.
+func (l *FileLock) RUnlock() error {
+	return l.Unlock()
+}
+
+func failFast(ok bool, err error) error {
 	if err != nil {
 		return err
 	}
+	if !ok {
+		return ErrWouldBlock
+	}
 	return nil
 }
 
-func (l *FileLock) Unlock() error {
-	defer l.f.Close()
-	return syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+// tryLock opens the file for every attempt, flock excludes the other open files of the same path,
+// even in the same process.
+func (l *FileLock) tryLock(how int) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// the open file holding the lock would leak, and locking it again through another file deadlocks
+	if l.f != nil {
+		return false, ErrAlreadyLocked
+	}
+
+	f, err := os.OpenFile(l.path, os.O_RDONLY|os.O_CREATE, lockFilePerm)
+	if err != nil {
+		return false, err
+	}
+
+	if err = syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	l.f, l.exclusive = f, how == syscall.LOCK_EX
+	if l.exclusive {
+		// the holder file is only for diagnostics, such as in a read only directory it is skipped
+		if err = l.writeHolder(); err != nil {
+			logx.Errorf("write file lock holder, path: %s, error: %v", l.Holder(), err)
+		} else {
+			l.holder = true
+		}
+	}
+	return true, nil
+}
+
+// Holder returns the file which the exclusive holder writes its pid and hostname to.
+func (l *FileLock) Holder() string {
+	return l.path + holderSuffix
+}
+
+// writeHolder writes the pid and hostname of the exclusive holder to the holder file, for diagnostics.
+// The lock file may be a data file of the caller, so it is never written.
+func (l *FileLock) writeHolder() error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return os.WriteFile(l.Holder(), []byte(fmt.Sprintf("pid: %d\nhostname: %s\n", os.Getpid(), hostname)), lockFilePerm)
 }
@@ -1,29 +1,198 @@
 package filelock
 
 import (
+	"context"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jzero-io/jzero-contrib/lock"
 )
 
 func TestFileLock(t *testing.T) {
+	dir := t.TempDir()
+	lockFile := filepath.Join(dir, "lock")
+	dataFile := filepath.Join(dir, "data")
+
 	wg := sync.WaitGroup{}
 	for i := 0; i < 1000; i++ {
 		wg.Add(1)
 		go func(num int) {
 			defer wg.Done()
-			flock, _ := New("testdata/config")
-			_ = flock.Lock()
+			flock, err := New(lockFile)
+			assert.NoError(t, err)
+			assert.NoError(t, flock.LockCtx(context.Background()))
 			defer func(flock *FileLock) {
-				_ = flock.Unlock()
+				assert.NoError(t, flock.Unlock())
 			}(flock)
 
-			f, _ := os.OpenFile("testdata/config", os.O_APPEND|os.O_WRONLY, 0o666)
+			f, _ := os.OpenFile(dataFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o666)
 			defer f.Close()
 			_, _ = f.WriteString("test" + cast.ToString(num) + "\n")
 		}(i)
 	}
 	wg.Wait()
+
+	data, err := os.ReadFile(dataFile)
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 1000)
+}
+
+func TestFileLockCtx(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "lock")
+	l1, err := New(lockFile)
+	assert.NoError(t, err)
+	l2, err := New(lockFile, WithBackoff(lock.ConstantBackoff(time.Millisecond*10)))
+	assert.NoError(t, err)
+
+	ok, err := l1.TryLock()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = l2.TryLock()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	// Lock fails fast
+	assert.ErrorIs(t, l2.Lock(), ErrWouldBlock)
+	assert.ErrorIs(t, l2.Lock(), syscall.EWOULDBLOCK)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	assert.ErrorIs(t, l2.LockCtx(ctx), context.DeadlineExceeded)
+
+	// LockCtx acquires the lock once it is released
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		_ = l1.Unlock()
+	}()
+	assert.NoError(t, l2.LockCtx(context.Background()))
+	assert.NoError(t, l2.Unlock())
+}
+
+func TestFileLockRelock(t *testing.T) {
+	// the lock file is created if missing
+	lockFile := filepath.Join(t.TempDir(), "lock")
+	l, err := New(lockFile)
+	assert.NoError(t, err)
+	assert.FileExists(t, lockFile)
+
+	assert.ErrorIs(t, l.Unlock(), ErrNotLocked)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, l.Lock())
+
+		data, err := os.ReadFile(l.Holder())
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), "pid: "+cast.ToString(os.Getpid())+"\n")
+		assert.Contains(t, string(data), "hostname: ")
+
+		assert.NoError(t, l.Unlock())
+		assert.NoFileExists(t, l.Holder())
+	}
+	assert.ErrorIs(t, l.Unlock(), ErrNotLocked)
+}
+
+func TestFileLockHolderUnwritable(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "lock")
+	l, err := New(lockFile)
+	assert.NoError(t, err)
+
+	// the holder file is best effort, the lock is acquired without it
+	assert.NoError(t, os.Mkdir(l.Holder(), 0o755))
+	assert.NoError(t, l.Lock())
+	assert.NoError(t, l.Unlock())
+	assert.DirExists(t, l.Holder())
+}
+
+func TestFileLockDataFile(t *testing.T) {
+	// locking a data file keeps its content
+	dataFile := filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, os.WriteFile(dataFile, []byte("data"), 0o644))
+
+	l, err := New(dataFile)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Lock())
+	assert.NoError(t, l.Unlock())
+	assert.NoError(t, l.RLock())
+	assert.NoError(t, l.RUnlock())
+
+	data, err := os.ReadFile(dataFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+}
+
+func TestFileLockAlreadyLocked(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "lock")
+	l, err := New(lockFile)
+	assert.NoError(t, err)
+
+	ok, err := l.TryRLock()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, err = l.TryRLock()
+	assert.ErrorIs(t, err, ErrAlreadyLocked)
+	// it does not wait for itself
+	assert.ErrorIs(t, l.LockCtx(context.Background()), ErrAlreadyLocked)
+	assert.NoError(t, l.RUnlock())
+
+	// the shared lock is released, so writers are not blocked by a leaked file
+	w, err := New(lockFile)
+	assert.NoError(t, err)
+	ok, err = w.TryLock()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, w.Unlock())
+}
+
+func TestFileLockRLock(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "lock")
+	var readers []*FileLock
+	for i := 0; i < 3; i++ {
+		l, err := New(lockFile)
+		assert.NoError(t, err)
+		ok, err := l.TryRLock()
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		readers = append(readers, l)
+	}
+
+	// readers exclude writers
+	w, err := New(lockFile, WithBackoff(lock.ConstantBackoff(time.Millisecond*10)))
+	assert.NoError(t, err)
+	ok, err := w.TryLock()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	var locked int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, w.LockCtx(context.Background()))
+		atomic.StoreInt32(&locked, 1)
+	}()
+	for _, r := range readers {
+		time.Sleep(time.Millisecond * 20)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&locked))
+		assert.NoError(t, r.RUnlock())
+	}
+	<-done
+
+	// writers exclude readers
+	ok, err = readers[0].TryRLock()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.ErrorIs(t, readers[0].RLock(), ErrWouldBlock)
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		assert.NoError(t, w.Unlock())
+	}()
+	assert.NoError(t, readers[0].RLockCtx(context.Background()))
+	assert.NoError(t, readers[0].RUnlock())
 }
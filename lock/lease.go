@@ -0,0 +1,173 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/threading"
+)
+
+type (
+	// Leaser supplies the lease of a lock driver, H identifies an acquisition, such as a random token,
+	// so only the acquisition holding the lease renews and releases it.
+	Leaser[H any] struct {
+		// Acquire takes the lease for its expiry, false means it is held by others.
+		Acquire func(ctx context.Context) (H, bool, error)
+		// Renew extends the lease held by holder for its expiry, false means the lease is lost.
+		Renew func(ctx context.Context, holder H) (bool, error)
+		// Release gives up the lease held by holder, false means the lease was lost.
+		Release func(ctx context.Context, holder H) (bool, error)
+	}
+
+	LeaseOpts struct {
+		// Name tells the lease in logs.
+		Name string
+
+		// Expiry is the lease taken by Acquire and Renew, the watchdog renews it every Expiry/3 while it is held.
+		Expiry time.Duration
+
+		// Timeout is how long Lock waits for the lease, 0 means waiting until it is acquired.
+		Timeout time.Duration
+
+		// Backoff is the wait between two attempts of Lock and LockCtx.
+		Backoff Backoff
+
+		// ErrTimeout is returned by Lock after the timeout.
+		ErrTimeout error
+
+		// ErrNotHeld is returned by Unlock when the lease is not held, or it is lost.
+		ErrNotHeld error
+	}
+
+	// Lease is a Lock on the lease of a Leaser, a watchdog renews the lease while it is held.
+	Lease[H any] struct {
+		leaser  Leaser[H]
+		options LeaseOpts
+
+		mu     sync.Mutex
+		held   bool
+		holder H
+		stop   chan struct{}
+		done   chan struct{}
+	}
+)
+
+var _ Lock = (*Lease[string])(nil)
+
+// NewLease creates a Lease on leaser, lock drivers such as redislock and sqllock are built on it.
+func NewLease[H any](leaser Leaser[H], o LeaseOpts) *Lease[H] {
+	return &Lease[H]{
+		leaser:  leaser,
+		options: o,
+	}
+}
+
+// Lock blocks until the lease is acquired, or returns ErrTimeout of the options after the timeout.
+func (l *Lease[H]) Lock() error {
+	if l.options.Timeout <= 0 {
+		return l.LockCtx(context.Background())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.options.Timeout)
+	defer cancel()
+	if err := l.LockCtx(ctx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return l.options.ErrTimeout
+		}
+		return err
+	}
+	return nil
+}
+
+// LockCtx blocks until the lease is acquired or ctx is done, the timeout option is not applied.
+func (l *Lease[H]) LockCtx(ctx context.Context) error {
+	return Retry(ctx, l.options.Backoff, func() (bool, error) {
+		return l.tryLock(ctx)
+	})
+}
+
+func (l *Lease[H]) TryLock() (bool, error) {
+	return l.tryLock(context.Background())
+}
+
+// Unlock releases the lease, ErrNotHeld of the options means it is not held or it was lost.
+func (l *Lease[H]) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.held {
+		return l.options.ErrNotHeld
+	}
+	close(l.stop)
+	<-l.done
+
+	holder := l.holder
+	var zero H
+	l.held, l.holder = false, zero
+	held, err := l.leaser.Release(context.Background(), holder)
+	if err != nil {
+		return err
+	}
+	if !held {
+		return l.options.ErrNotHeld
+	}
+	return nil
+}
+
+// Holder returns the acquisition holding the lease, false if it is not held.
+func (l *Lease[H]) Holder() (H, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.holder, l.held
+}
+
+func (l *Lease[H]) tryLock(ctx context.Context) (bool, error) {
+	holder, ok, err := l.leaser.Acquire(ctx)
+	if err != nil || !ok {
+		return false, err
+	}
+	l.hold(holder)
+	return true, nil
+}
+
+// hold records the holder and starts the watchdog.
+func (l *Lease[H]) hold(holder H) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.held, l.holder = true, holder
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	stop, done := l.stop, l.done
+	threading.GoSafe(func() {
+		defer close(done)
+		l.watch(holder, stop)
+	})
+}
+
+// watch renews the lease until stop is closed or the lease is lost.
+func (l *Lease[H]) watch(holder H, stop chan struct{}) {
+	ticker := time.NewTicker(l.options.Expiry / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			held, err := l.leaser.Renew(context.Background(), holder)
+			if err != nil {
+				// retry on the next tick, the lease lasts for the other two ticks
+				logx.Errorf("renew %s, error: %v", l.options.Name, err)
+				continue
+			}
+			if !held {
+				logx.Errorf("%s lost", l.options.Name)
+				return
+			}
+		}
+	}
+}
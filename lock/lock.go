@@ -1,6 +1,65 @@
 package lock
 
+import (
+	"context"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/mathx"
+)
+
+const backoffDeviation = 0.2
+
 type Lock interface {
+	// Lock acquires the lock, filelock fails fast while redislock and sqllock wait until their timeout
 	Lock() error
+	// LockCtx blocks until the lock is acquired or ctx is done
+	LockCtx(ctx context.Context) error
+	// TryLock acquires the lock without blocking, false means it is held by others
+	TryLock() (bool, error)
 	Unlock() error
 }
+
+// Backoff returns how long to wait before the attempt-th retry of acquiring a lock, attempt starts from 1.
+type Backoff func(attempt int) time.Duration
+
+// ConstantBackoff waits interval before every retry.
+func ConstantBackoff(interval time.Duration) Backoff {
+	return func(int) time.Duration {
+		return interval
+	}
+}
+
+// ExponentialBackoff doubles the wait from minWait up to maxWait, the waits are jittered
+// so that contenders do not retry in lockstep.
+func ExponentialBackoff(minWait, maxWait time.Duration) Backoff {
+	unstable := mathx.NewUnstable(backoffDeviation)
+	return func(attempt int) time.Duration {
+		wait := minWait
+		for i := 1; i < attempt && wait < maxWait; i++ {
+			wait *= 2
+		}
+		return unstable.AroundDuration(min(wait, maxWait))
+	}
+}
+
+// Retry calls try until it acquires the lock or fails, waiting by backoff in between,
+// it returns ctx.Err() once ctx is done.
+func Retry(ctx context.Context, backoff Backoff, try func() (bool, error)) error {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ok, err := try()
+		if err != nil || ok {
+			return err
+		}
+
+		timer := time.NewTimer(backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
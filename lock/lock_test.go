@@ -0,0 +1,119 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Millisecond*10, time.Millisecond*100)
+	assert.InDelta(t, float64(time.Millisecond*10), float64(backoff(1)), float64(time.Millisecond*2))
+	assert.InDelta(t, float64(time.Millisecond*20), float64(backoff(2)), float64(time.Millisecond*4))
+	assert.InDelta(t, float64(time.Millisecond*80), float64(backoff(4)), float64(time.Millisecond*16))
+	assert.InDelta(t, float64(time.Millisecond*100), float64(backoff(5)), float64(time.Millisecond*20))
+	assert.InDelta(t, float64(time.Millisecond*100), float64(backoff(100)), float64(time.Millisecond*20))
+}
+
+func TestRetry(t *testing.T) {
+	var attempts []int
+	err := Retry(context.Background(), func(attempt int) time.Duration {
+		attempts = append(attempts, attempt)
+		return time.Millisecond
+	}, func() (bool, error) {
+		return len(attempts) == 2, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, attempts)
+
+	errLock := errors.New("lock error")
+	err = Retry(context.Background(), ConstantBackoff(time.Millisecond), func() (bool, error) {
+		return false, errLock
+	})
+	assert.ErrorIs(t, err, errLock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	start := time.Now()
+	err = Retry(ctx, ConstantBackoff(time.Second), func() (bool, error) {
+		return false, nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestLease(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		owner  int
+		next   int
+		renews int
+	)
+	errTimeout, errNotHeld := errors.New("timeout"), errors.New("not held")
+	newLease := func() *Lease[int] {
+		return NewLease(Leaser[int]{
+			Acquire: func(context.Context) (int, bool, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if owner != 0 {
+					return 0, false, nil
+				}
+				next++
+				owner = next
+				return owner, true, nil
+			},
+			Renew: func(_ context.Context, holder int) (bool, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				renews++
+				return owner == holder, nil
+			},
+			Release: func(_ context.Context, holder int) (bool, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if owner != holder {
+					return false, nil
+				}
+				owner = 0
+				return true, nil
+			},
+		}, LeaseOpts{
+			Name:       "test lease",
+			Expiry:     time.Millisecond * 30,
+			Timeout:    time.Millisecond * 50,
+			Backoff:    ConstantBackoff(time.Millisecond * 5),
+			ErrTimeout: errTimeout,
+			ErrNotHeld: errNotHeld,
+		})
+	}
+
+	l1, l2 := newLease(), newLease()
+	assert.ErrorIs(t, l1.Unlock(), errNotHeld)
+	assert.NoError(t, l1.Lock())
+	holder, ok := l1.Holder()
+	assert.True(t, ok)
+	assert.Equal(t, 1, holder)
+
+	// the watchdog keeps the lease while it is held
+	assert.ErrorIs(t, l2.Lock(), errTimeout)
+	mu.Lock()
+	assert.Positive(t, renews)
+	mu.Unlock()
+
+	assert.NoError(t, l1.Unlock())
+	_, ok = l1.Holder()
+	assert.False(t, ok)
+	ok, err := l2.TryLock()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// a lost lease is reported by Unlock
+	mu.Lock()
+	owner = 0
+	mu.Unlock()
+	assert.ErrorIs(t, l2.Unlock(), errNotHeld)
+}
@@ -0,0 +1,180 @@
+package redislock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/eddieowens/opts"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zeromicro/go-zero/core/stringx"
+
+	"github.com/jzero-io/jzero-contrib/lock"
+)
+
+var _ lock.Lock = (*RedisLock)(nil)
+
+const (
+	defaultExpiry        = time.Second * 30
+	defaultRetryInterval = time.Millisecond * 100
+	tokenLen             = 16
+)
+
+var (
+	// ErrTimeout is returned by Lock when the lock is not acquired within the timeout.
+	ErrTimeout = errors.New("redislock: lock timeout")
+
+	// ErrNotHeld is returned by Unlock when the lock is not held, or its lease is lost.
+	ErrNotHeld = errors.New("redislock: lock not held")
+)
+
+// scripts are sent by EVAL, the NOSCRIPT replies of EVALSHA on a fresh redis would trip the go-zero breaker
+// when many instances race for a lock.
+const (
+	lockScript = `if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+    return 1
+end
+return 0`
+
+	unlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+	renewScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`
+)
+
+type (
+	LockOpts struct {
+		// Expiry is the lease of the lock, the watchdog renews it every Expiry/3 while the lock is held.
+		Expiry time.Duration
+
+		// Timeout is how long Lock waits for the lock, 0 means waiting until it is acquired.
+		Timeout time.Duration
+
+		// Backoff is the wait between two attempts of Lock and LockCtx, default 100ms.
+		Backoff lock.Backoff
+	}
+
+	// RedisLock is a distributed lock held by SET NX PX with a token unique to every acquisition,
+	// only the holder of the token is able to renew and release it.
+	RedisLock struct {
+		rds     *redis.Redis
+		key     string
+		options LockOpts
+		lease   *lock.Lease[string]
+	}
+)
+
+func (opts LockOpts) DefaultOptions() LockOpts {
+	return LockOpts{
+		Expiry:  defaultExpiry,
+		Backoff: lock.ConstantBackoff(defaultRetryInterval),
+	}
+}
+
+func WithExpiry(expiry time.Duration) opts.Opt[LockOpts] {
+	return func(o *LockOpts) {
+		o.Expiry = expiry
+	}
+}
+
+func WithTimeout(timeout time.Duration) opts.Opt[LockOpts] {
+	return func(o *LockOpts) {
+		o.Timeout = timeout
+	}
+}
+
+func WithBackoff(backoff lock.Backoff) opts.Opt[LockOpts] {
+	return func(o *LockOpts) {
+		o.Backoff = backoff
+	}
+}
+
+// WithRetryInterval retries Lock and LockCtx at a constant interval.
+func WithRetryInterval(interval time.Duration) opts.Opt[LockOpts] {
+	return WithBackoff(lock.ConstantBackoff(interval))
+}
+
+// New creates a lock of key, instances created with the same key exclude each other across hosts.
+func New(rds *redis.Redis, key string, op ...opts.Opt[LockOpts]) *RedisLock {
+	o := opts.DefaultApply(op...)
+	if o.Expiry < time.Millisecond {
+		o.Expiry = defaultExpiry
+	}
+	if o.Backoff == nil {
+		o.Backoff = lock.ConstantBackoff(defaultRetryInterval)
+	}
+
+	l := &RedisLock{
+		rds:     rds,
+		key:     key,
+		options: o,
+	}
+	l.lease = lock.NewLease(lock.Leaser[string]{
+		Acquire: l.acquire,
+		Renew:   l.renew,
+		Release: l.release,
+	}, lock.LeaseOpts{
+		Name:       "redis lock " + key,
+		Expiry:     o.Expiry,
+		Timeout:    o.Timeout,
+		Backoff:    o.Backoff,
+		ErrTimeout: ErrTimeout,
+		ErrNotHeld: ErrNotHeld,
+	})
+	return l
+}
+
+// Lock blocks until the lock is acquired, or returns ErrTimeout after the timeout.
+func (l *RedisLock) Lock() error {
+	return l.lease.Lock()
+}
+
+// LockCtx blocks until the lock is acquired or ctx is done, the timeout option is not applied.
+func (l *RedisLock) LockCtx(ctx context.Context) error {
+	return l.lease.LockCtx(ctx)
+}
+
+func (l *RedisLock) TryLock() (bool, error) {
+	return l.lease.TryLock()
+}
+
+// Unlock releases the lock, ErrNotHeld means it is not held or its lease was lost.
+func (l *RedisLock) Unlock() error {
+	return l.lease.Unlock()
+}
+
+// acquire sets the key to a new token unique to the acquisition.
+func (l *RedisLock) acquire(ctx context.Context) (string, bool, error) {
+	token := stringx.Randn(tokenLen)
+	resp, err := l.rds.EvalCtx(ctx, lockScript, []string{l.key}, token, l.expiryMillis())
+	if err != nil {
+		return "", false, err
+	}
+	return token, resp == int64(1), nil
+}
+
+func (l *RedisLock) renew(ctx context.Context, token string) (bool, error) {
+	resp, err := l.rds.EvalCtx(ctx, renewScript, []string{l.key}, token, l.expiryMillis())
+	if err != nil {
+		return false, err
+	}
+	return resp == int64(1), nil
+}
+
+func (l *RedisLock) release(ctx context.Context, token string) (bool, error) {
+	resp, err := l.rds.EvalCtx(ctx, unlockScript, []string{l.key}, token)
+	if err != nil {
+		return false, err
+	}
+	return resp == int64(1), nil
+}
+
+func (l *RedisLock) expiryMillis() string {
+	return strconv.FormatInt(l.options.Expiry.Milliseconds(), 10)
+}
@@ -0,0 +1,116 @@
+package redislock
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+
+	"github.com/jzero-io/jzero-contrib/lock"
+)
+
+func TestRedisLock(t *testing.T) {
+	r := miniredis.RunT(t)
+	rds := redis.New(r.Addr())
+
+	l1 := New(rds, "lock")
+	l2 := New(rds, "lock", WithTimeout(time.Millisecond*300), WithRetryInterval(time.Millisecond*50))
+
+	assert.NoError(t, l1.Lock())
+	assert.True(t, r.Exists("lock"))
+
+	start := time.Now()
+	assert.ErrorIs(t, l2.Lock(), ErrTimeout)
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*300)
+	// only the holder releases the lock
+	assert.ErrorIs(t, l2.Unlock(), ErrNotHeld)
+
+	assert.NoError(t, l1.Unlock())
+	assert.False(t, r.Exists("lock"))
+	assert.ErrorIs(t, l1.Unlock(), ErrNotHeld)
+
+	assert.NoError(t, l2.Lock())
+	assert.NoError(t, l2.Unlock())
+}
+
+func TestRedisLockBlocking(t *testing.T) {
+	r := miniredis.RunT(t)
+	rds := redis.New(r.Addr())
+
+	var (
+		wg      sync.WaitGroup
+		holders int32
+		count   int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := New(rds, "lock", WithRetryInterval(time.Millisecond*10))
+			assert.NoError(t, l.Lock())
+			assert.Equal(t, int32(1), atomic.AddInt32(&holders, 1))
+			count++
+			time.Sleep(time.Millisecond * 10)
+			atomic.AddInt32(&holders, -1)
+			assert.NoError(t, l.Unlock())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, count)
+}
+
+func TestRedisLockWatchdog(t *testing.T) {
+	r := miniredis.RunT(t)
+	rds := redis.New(r.Addr())
+
+	l := New(rds, "lock", WithExpiry(time.Millisecond*300))
+	assert.NoError(t, l.Lock())
+
+	// the watchdog renews the lease every 100ms, so it outlives the expiry
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond * 150)
+		r.FastForward(time.Millisecond * 200)
+		assert.True(t, r.Exists("lock"))
+	}
+	assert.NoError(t, l.Unlock())
+
+	// a lost lease is not released by the former holder
+	assert.NoError(t, l.Lock())
+	r.Del("lock")
+	assert.NoError(t, r.Set("lock", "other"))
+	assert.ErrorIs(t, l.Unlock(), ErrNotHeld)
+	v, err := r.Get("lock")
+	assert.NoError(t, err)
+	assert.Equal(t, "other", v)
+}
+
+func TestRedisLockCtx(t *testing.T) {
+	r := miniredis.RunT(t)
+	rds := redis.New(r.Addr())
+
+	l1 := New(rds, "lock")
+	l2 := New(rds, "lock", WithBackoff(lock.ExponentialBackoff(time.Millisecond*5, time.Millisecond*20)))
+
+	ok, err := l1.TryLock()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = l2.TryLock()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	assert.ErrorIs(t, l2.LockCtx(ctx), context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		assert.NoError(t, l1.Unlock())
+	}()
+	assert.NoError(t, l2.LockCtx(context.Background()))
+	assert.NoError(t, l2.Unlock())
+}
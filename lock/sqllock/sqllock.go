@@ -0,0 +1,260 @@
+package sqllock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/eddieowens/opts"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/zeromicro/go-zero/core/stores/sqlx"
+	"github.com/zeromicro/go-zero/core/stringx"
+
+	"github.com/jzero-io/jzero-contrib/lock"
+)
+
+const (
+	defaultTable         = "jzero_lock"
+	defaultExpiry        = time.Second * 30
+	defaultRetryInterval = time.Second
+	ownerLen             = 16
+)
+
+var (
+	// ErrTimeout is returned by Lock when the lock is not acquired within the timeout.
+	ErrTimeout = errors.New("sqllock: lock timeout")
+
+	// ErrNotHeld is returned by Unlock when the lock is not held, or its lease is lost.
+	ErrNotHeld = errors.New("sqllock: lock not held")
+)
+
+var _ lock.Lock = (*SqlLock)(nil)
+
+type (
+	LockOpts struct {
+		// Table is the lease table, see CreateTable.
+		Table string
+
+		// Expiry is the lease of the lock, the watchdog renews it every Expiry/3 while the lock is held.
+		Expiry time.Duration
+
+		// Timeout is how long Lock waits for the lock, 0 means waiting until it is acquired.
+		Timeout time.Duration
+
+		// Backoff is the wait between two attempts of Lock and LockCtx, default 1s.
+		Backoff lock.Backoff
+	}
+
+	// SqlLock is a lease in a table, a row per lock name, so it works on every database supported by modelx.
+	// Every acquisition increases the token of the row, the token fences the writes of a former holder
+	// whose lease expired. Leases are compared with the clocks of the holders, which should be synchronized.
+	SqlLock struct {
+		conn    sqlx.SqlConn
+		name    string
+		options LockOpts
+		lease   *lock.Lease[holder]
+	}
+
+	// holder is an acquisition of the lease, owner is unique to it and token fences it.
+	holder struct {
+		owner string
+		token int64
+	}
+)
+
+func (opts LockOpts) DefaultOptions() LockOpts {
+	return LockOpts{
+		Table:   defaultTable,
+		Expiry:  defaultExpiry,
+		Backoff: lock.ConstantBackoff(defaultRetryInterval),
+	}
+}
+
+func WithTable(table string) opts.Opt[LockOpts] {
+	return func(o *LockOpts) {
+		o.Table = table
+	}
+}
+
+func WithExpiry(expiry time.Duration) opts.Opt[LockOpts] {
+	return func(o *LockOpts) {
+		o.Expiry = expiry
+	}
+}
+
+func WithTimeout(timeout time.Duration) opts.Opt[LockOpts] {
+	return func(o *LockOpts) {
+		o.Timeout = timeout
+	}
+}
+
+func WithBackoff(backoff lock.Backoff) opts.Opt[LockOpts] {
+	return func(o *LockOpts) {
+		o.Backoff = backoff
+	}
+}
+
+// CreateTable creates the lease table if it does not exist, the statement is valid on mysql, sqlite and postgres.
+func CreateTable(ctx context.Context, conn sqlx.SqlConn, table string) error {
+	_, err := conn.ExecCtx(ctx, "CREATE TABLE IF NOT EXISTS "+table+" ("+
+		"name VARCHAR(191) NOT NULL PRIMARY KEY, "+
+		"owner VARCHAR(64) NOT NULL, "+
+		"token BIGINT NOT NULL, "+
+		"expire_at BIGINT NOT NULL)")
+	return err
+}
+
+// New creates a lock of name on conn, such as the one returned by modelx.MustSqlxConn.
+// Statements are built with sqlbuilder.DefaultFlavor, which modelx sets by the database type.
+func New(conn sqlx.SqlConn, name string, op ...opts.Opt[LockOpts]) *SqlLock {
+	o := opts.DefaultApply(op...)
+	if o.Table == "" {
+		o.Table = defaultTable
+	}
+	if o.Expiry < time.Millisecond {
+		o.Expiry = defaultExpiry
+	}
+	if o.Backoff == nil {
+		o.Backoff = lock.ConstantBackoff(defaultRetryInterval)
+	}
+
+	l := &SqlLock{
+		conn:    conn,
+		name:    name,
+		options: o,
+	}
+	l.lease = lock.NewLease(lock.Leaser[holder]{
+		Acquire: l.acquire,
+		Renew:   l.renew,
+		Release: l.release,
+	}, lock.LeaseOpts{
+		Name:       "sql lock " + name,
+		Expiry:     o.Expiry,
+		Timeout:    o.Timeout,
+		Backoff:    o.Backoff,
+		ErrTimeout: ErrTimeout,
+		ErrNotHeld: ErrNotHeld,
+	})
+	return l
+}
+
+// Lock blocks until the lock is acquired, or returns ErrTimeout after the timeout.
+func (l *SqlLock) Lock() error {
+	return l.lease.Lock()
+}
+
+// LockCtx blocks until the lock is acquired or ctx is done, the timeout option is not applied.
+func (l *SqlLock) LockCtx(ctx context.Context) error {
+	return l.lease.LockCtx(ctx)
+}
+
+func (l *SqlLock) TryLock() (bool, error) {
+	return l.lease.TryLock()
+}
+
+// Unlock releases the lock, ErrNotHeld means it is not held or its lease was lost.
+func (l *SqlLock) Unlock() error {
+	return l.lease.Unlock()
+}
+
+// Token returns the fencing token of the lock, 0 if it is not held. Tokens of a name only increase,
+// so a resource is able to reject the writes carrying a token lower than the one it has seen.
+func (l *SqlLock) Token() int64 {
+	h, _ := l.lease.Holder()
+	return h.token
+}
+
+func (l *SqlLock) acquire(ctx context.Context) (holder, bool, error) {
+	owner := stringx.Randn(ownerLen)
+	now := time.Now()
+	expireAt := now.Add(l.options.Expiry).UnixMilli()
+
+	// take over an expired or released lease
+	ub := sqlbuilder.NewUpdateBuilder()
+	ub.Update(l.options.Table).
+		Set(ub.Assign("owner", owner), ub.Incr("token"), ub.Assign("expire_at", expireAt)).
+		Where(ub.Equal("name", l.name), ub.LessEqualThan("expire_at", now.UnixMilli()))
+	ok, err := l.exec(ctx, ub)
+	if err != nil {
+		return holder{}, false, err
+	}
+
+	if !ok {
+		if ok, err = l.insert(ctx, owner, expireAt); err != nil || !ok {
+			return holder{}, false, err
+		}
+	}
+
+	var token int64
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.Select("token").From(l.options.Table).Where(sb.Equal("name", l.name), sb.Equal("owner", owner))
+	query, args := sb.Build()
+	if err = l.conn.QueryRowCtx(ctx, &token, query, args...); err != nil {
+		return holder{}, false, err
+	}
+	return holder{owner: owner, token: token}, true, nil
+}
+
+// insert creates the row of the lock, false means the lock is held by others.
+func (l *SqlLock) insert(ctx context.Context, owner string, expireAt int64) (bool, error) {
+	// check first, so a held lock does not cost a duplicate key error
+	if exists, err := l.exists(ctx); err != nil || exists {
+		return false, err
+	}
+
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.InsertInto(l.options.Table).
+		Cols("name", "owner", "token", "expire_at").
+		Values(l.name, owner, 1, expireAt)
+	query, args := ib.Build()
+	if _, err := l.conn.ExecCtx(ctx, query, args...); err != nil {
+		// lost the race of creating the row to another instance
+		if exists, e := l.exists(ctx); e == nil && exists {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *SqlLock) exists(ctx context.Context) (bool, error) {
+	var count int64
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.Select("COUNT(*)").From(l.options.Table).Where(sb.Equal("name", l.name))
+	query, args := sb.Build()
+	if err := l.conn.QueryRowCtx(ctx, &count, query, args...); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// exec runs ub, true means a row is updated.
+func (l *SqlLock) exec(ctx context.Context, ub *sqlbuilder.UpdateBuilder) (bool, error) {
+	query, args := ub.Build()
+	result, err := l.conn.ExecCtx(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (l *SqlLock) renew(ctx context.Context, h holder) (bool, error) {
+	ub := sqlbuilder.NewUpdateBuilder()
+	ub.Update(l.options.Table).
+		Set(ub.Assign("expire_at", time.Now().Add(l.options.Expiry).UnixMilli())).
+		Where(ub.Equal("name", l.name), ub.Equal("owner", h.owner), ub.Equal("token", h.token))
+	return l.exec(ctx, ub)
+}
+
+func (l *SqlLock) release(ctx context.Context, h holder) (bool, error) {
+	// keep the row, so the token keeps increasing
+	ub := sqlbuilder.NewUpdateBuilder()
+	ub.Update(l.options.Table).
+		Set(ub.Assign("expire_at", 0)).
+		Where(ub.Equal("name", l.name), ub.Equal("owner", h.owner), ub.Equal("token", h.token))
+	return l.exec(ctx, ub)
+}
@@ -0,0 +1,121 @@
+package sqllock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/stores/sqlx"
+
+	"github.com/jzero-io/jzero-contrib/lock"
+)
+
+const (
+	takeOverSQL = "UPDATE jzero_lock SET owner = ?, token = token + 1, expire_at = ? WHERE name = ? AND expire_at <= ?"
+	existsSQL   = "SELECT COUNT(*) FROM jzero_lock WHERE name = ?"
+	insertSQL   = "INSERT INTO jzero_lock (name, owner, token, expire_at) VALUES (?, ?, ?, ?)"
+	tokenSQL    = "SELECT token FROM jzero_lock WHERE name = ? AND owner = ?"
+	releaseSQL  = "UPDATE jzero_lock SET expire_at = ? WHERE name = ? AND owner = ? AND token = ?"
+)
+
+func newMock(t *testing.T) (sqlx.SqlConn, sqlmock.Sqlmock) {
+	sqlbuilder.DefaultFlavor = sqlbuilder.MySQL
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		_ = db.Close()
+	})
+	return sqlx.NewSqlConnFromDB(db), mock
+}
+
+func TestSqlLock(t *testing.T) {
+	conn, mock := newMock(t)
+	l := New(conn, "cron")
+
+	// the first lock creates the row
+	mock.ExpectExec(takeOverSQL).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "cron", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(existsSQL).WithArgs("cron").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(insertSQL).WithArgs("cron", sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(tokenSQL).WithArgs("cron", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"token"}).AddRow(1))
+	assert.NoError(t, l.Lock())
+	assert.Equal(t, int64(1), l.Token())
+
+	mock.ExpectExec(releaseSQL).WithArgs(0, "cron", sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, l.Unlock())
+	assert.Equal(t, int64(0), l.Token())
+	assert.ErrorIs(t, l.Unlock(), ErrNotHeld)
+
+	// later locks take over the released lease with a greater token
+	mock.ExpectExec(takeOverSQL).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "cron", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(tokenSQL).WithArgs("cron", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"token"}).AddRow(2))
+	ok, err := l.TryLock()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), l.Token())
+
+	// the lease was taken over after it expired
+	mock.ExpectExec(releaseSQL).WithArgs(0, "cron", sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, l.Unlock(), ErrNotHeld)
+}
+
+func TestSqlLockHeld(t *testing.T) {
+	conn, mock := newMock(t)
+	l := New(conn, "cron", WithTimeout(time.Millisecond*50), WithBackoff(lock.ConstantBackoff(time.Millisecond*100)))
+
+	mock.ExpectExec(takeOverSQL).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "cron", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(existsSQL).WithArgs("cron").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	ok, err := l.TryLock()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	mock.ExpectExec(takeOverSQL).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "cron", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(existsSQL).WithArgs("cron").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	assert.ErrorIs(t, l.Lock(), ErrTimeout)
+
+	// another instance created the row first
+	mock.ExpectExec(takeOverSQL).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "cron", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(existsSQL).WithArgs("cron").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(insertSQL).WithArgs("cron", sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnError(assert.AnError)
+	mock.ExpectQuery(existsSQL).WithArgs("cron").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	ok, err = l.TryLock()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestSqlLockWatchdog(t *testing.T) {
+	conn, mock := newMock(t)
+	l := New(conn, "cron", WithExpiry(time.Millisecond*300))
+
+	// sqlmock is not safe to expect while the watchdog runs, so expect everything first
+	mock.ExpectExec(takeOverSQL).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "cron", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(tokenSQL).WithArgs("cron", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"token"}).AddRow(3))
+	// renewed every 100ms
+	for i := 0; i < 2; i++ {
+		mock.ExpectExec(releaseSQL).WithArgs(sqlmock.AnyArg(), "cron", sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec(releaseSQL).WithArgs(0, "cron", sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, l.LockCtx(context.Background()))
+	time.Sleep(time.Millisecond * 250)
+	assert.NoError(t, l.Unlock())
+}
+
+func TestCreateTable(t *testing.T) {
+	conn, mock := newMock(t)
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS jzero_lock (name VARCHAR(191) NOT NULL PRIMARY KEY, " +
+		"owner VARCHAR(64) NOT NULL, token BIGINT NOT NULL, expire_at BIGINT NOT NULL)").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.NoError(t, CreateTable(context.Background(), conn, "jzero_lock"))
+}